@@ -0,0 +1,83 @@
+package sqlz
+
+import (
+	"context"
+	"reflect"
+)
+
+// ScanChanBatched scans the result set from rows into values of type T, and sends them to ch in batches of
+// batchSize rows. The last batch holds the remaining rows and can be smaller. Every batch is a new slice, owned by
+// the receiver. Compared to scanning into a channel of T, this reduces the synchronization per row, which matters
+// for high-throughput streams. If s is nil, the global Scanner is used. See [Scanner.Scan] for more details, T can
+// be any type that a slice destination of Scan accepts as element.
+//
+// ScanChanBatched stops when the context is canceled, rows that aren't sent yet are dropped.
+// See also [Scanner.CloseChanOnDone].
+func ScanChanBatched[T any](ctx context.Context, s *Scanner, rows Rows, ch chan<- []T, batchSize int) (err error) {
+	if s == nil {
+		s = Default()
+	}
+	defer s.recoverMisuse(&err)
+	if batchSize <= 0 {
+		panic("batch size must be positive")
+	}
+	opts := s.options()
+	opts.ctx = ctx
+	if opts.closeChan {
+		defer close(ch)
+	}
+	var (
+		v        T
+		elem     = reflect.ValueOf(&v).Elem()
+		elemType = elem.Type()
+		isPtr    = elemType.Kind() == reflect.Pointer && !s.isScalar(elemType)
+	)
+	if isPtr {
+		elemType = elemType.Elem()
+		elem = reflect.New(elemType).Elem()
+	}
+	p, err := s.mapDest(elem, rows, &opts)
+	if err != nil {
+		return err
+	}
+	defer p.release()
+	p.ownRawBytes = true
+	var (
+		done  = ctx.Done()
+		batch = make([]T, 0, batchSize)
+	)
+	send := func() error {
+		select {
+		case <-done:
+			return ctx.Err() // don't race the send if ctx is already done
+		default:
+		}
+		select {
+		case ch <- batch:
+			batch = make([]T, 0, batchSize)
+			return nil
+		case <-done:
+			return ctx.Err()
+		}
+	}
+	err = scanRows(ctx, rows, p, elem, func() error {
+		if isPtr {
+			ptr := reflect.New(elemType)
+			ptr.Elem().Set(elem)
+			batch = append(batch, ptr.Interface().(T))
+		} else {
+			batch = append(batch, elem.Interface().(T))
+		}
+		if len(batch) == batchSize {
+			return send()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return send()
+	}
+	return nil
+}
@@ -1,80 +1,502 @@
 package sqlz
 
 import (
+	"encoding/json"
+	"fmt"
 	"maps"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+
+	"github.com/semrekkers/sqlz/internal/tags"
 )
 
-type structFieldIndex map[string][]uint16
+type structFieldIndex map[string]*fieldInfo
+
+// fieldInfo describes a struct field that maps to a column.
+type fieldInfo struct {
+	name   string
+	index  []uint16
+	setter *reflect.Method // setter of the struct that contains the field, nil if there is none
+	conv   converter       // converter selected by the field's tag options, nil if there is none
+	copies [][]uint16      // indexes of fields of the same struct that receive a copy of the field's value
+	parent []uint16        // index of the pointer to struct field that holds the field, nil if there is none
+}
 
 type cache struct {
-	types atomic.Pointer[map[reflect.Type]structFieldIndex]
-	mu    sync.Mutex
+	types  atomic.Pointer[map[reflect.Type][]typeEntry]
+	mu     sync.Mutex
+	names  map[reflect.Type]map[string]string // registered column names of fields by type, guarded by mu
+	kinds  atomic.Pointer[kindMap]            // registered converters by field type, replaced under mu
+	warned sync.Map                           // ignored columns that were logged, by warnKey
+	tags   atomic.Pointer[joinedTags]         // last tag priority of an indexKey
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// indexKey holds the options that affect the field index of a struct type.
+type indexKey struct {
+	prefixSeparator string
+	tagPriority     string // tag keys joined by spaces, which keys can't contain
+	hasTagPriority  bool
+	dialect         string
+	maxDepth        int
+}
+
+// typeEntry is the field index of a struct type, built with the options in key. Struct types are mostly scanned
+// with the same options, so the entries of a type are searched linearly.
+type typeEntry struct {
+	key  indexKey
+	info *structInfo
 }
 
-func (c *cache) load() (x map[reflect.Type]structFieldIndex) {
+func (c *cache) load() (x map[reflect.Type][]typeEntry) {
 	if ptr := c.types.Load(); ptr != nil {
 		x = *ptr
 	}
 	return
 }
 
-func (c *cache) getStructFieldIndex(t reflect.Type) structFieldIndex {
-	if x, ok := c.load()[t]; ok {
+// find returns the field index of t built with the options in key, or nil if there's none.
+func find(types map[reflect.Type][]typeEntry, t reflect.Type, key indexKey) *structInfo {
+	for _, e := range types[t] {
+		if e.key == key {
+			return e.info
+		}
+	}
+	return nil
+}
+
+func (c *cache) getStructInfo(t reflect.Type, opts *indexOptions) *structInfo {
+	key := indexKey{
+		prefixSeparator: opts.prefixSeparator,
+		tagPriority:     c.joinTags(opts.tagPriority),
+		hasTagPriority:  opts.tagPriority != nil,
+		dialect:         opts.dialect,
+		maxDepth:        opts.maxDepth,
+	}
+	if x := find(c.load(), t, key); x != nil {
+		c.hits.Add(1)
 		return x // fast path
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	types := c.load()
-	if x, ok := types[t]; ok {
+	if x := find(types, t, key); x != nil {
+		c.hits.Add(1)
 		return x
-	} else if types != nil {
+	}
+	c.misses.Add(1)
+	if types != nil {
 		types = maps.Clone(types)
 	} else {
-		types = make(map[reflect.Type]structFieldIndex, 1)
+		types = make(map[reflect.Type][]typeEntry, 1)
 	}
-	x := make(structFieldIndex, t.NumField())
-	fillStructFieldIndex(x, t, nil, "")
-	types[t] = x
+	o := *opts
+	o.names = c.names
+	o.kinds = c.kinds.Load().get()
+	x := newStructInfo(t, &o)
+	types[t] = append(slices.Clip(types[t]), typeEntry{key, x})
 	c.types.Store(&types)
 	return x
 }
 
+// joinedTags is a tag priority joined by spaces.
+type joinedTags struct {
+	tags   []string
+	joined string
+}
+
+// joinTags returns tags joined by spaces. The last result is reused, so the key of the type cache isn't joined again
+// on every scan.
+func (c *cache) joinTags(tags []string) string {
+	if len(tags) < 2 {
+		return strings.Join(tags, " ") // doesn't allocate
+	}
+	if last := c.tags.Load(); last != nil && slices.Equal(last.tags, tags) {
+		return last.joined
+	}
+	joined := strings.Join(tags, " ")
+	c.tags.Store(&joinedTags{slices.Clone(tags), joined})
+	return joined
+}
+
+// warnKey identifies an ignored column of a struct type.
+type warnKey struct {
+	t      reflect.Type
+	column string
+}
+
+// warnOnce reports whether the ignored column of t wasn't logged before, and records that it is.
+func (c *cache) warnOnce(t reflect.Type, column string) bool {
+	_, loaded := c.warned.LoadOrStore(warnKey{t, column}, struct{}{})
+	return !loaded
+}
+
+func (c *cache) stats() CacheStats {
+	return CacheStats{
+		Types:  c.numTypes(),
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
+
+// numTypes returns the number of field indexes in c.
+func (c *cache) numTypes() (n int) {
+	for _, entries := range c.load() {
+		n += len(entries)
+	}
+	return
+}
+
+// register sets the column names of the fields of t, and purges the cache so they take effect.
+func (c *cache) register(t reflect.Type, names map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.names == nil {
+		c.names = make(map[reflect.Type]map[string]string)
+	}
+	c.names[t] = maps.Clone(names)
+	c.types.Store(nil)
+}
+
+// registerKind sets the converter for fields of type t, and purges the cache so it takes effect.
+func (c *cache) registerKind(t reflect.Type, conv converter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.kinds.Store(c.kinds.Load().with(t, conv))
+	c.types.Store(nil)
+}
+
+// kind returns the converter registered for t on c, or else globally, or nil if there's none.
+func (c *cache) kind(t reflect.Type) converter {
+	o := indexOptions{kinds: c.kinds.Load().get()}
+	return o.kind(t)
+}
+
+// kindMap holds registered converters by field type. It's never changed once stored, so it's read without locking.
+type kindMap map[reflect.Type]converter
+
+// get returns the map m points to, which is nil if m is nil.
+func (m *kindMap) get() map[reflect.Type]converter {
+	if m == nil {
+		return nil
+	}
+	return *m
+}
+
+// with returns a copy of the map m points to, with conv set for t.
+func (m *kindMap) with(t reflect.Type, conv converter) *kindMap {
+	x := kindMap(maps.Clone(m.get()))
+	if x == nil {
+		x = make(kindMap, 1)
+	}
+	x[t] = conv
+	return &x
+}
+
 func (c *cache) purge() {
 	c.mu.Lock()
 	c.types.Store(nil)
 	c.mu.Unlock()
 }
 
-func fillStructFieldIndex(dest structFieldIndex, t reflect.Type, cursor []uint16, prefix string) {
+// indexOptions control how the fields of a struct type map to columns.
+type indexOptions struct {
+	prefixSeparator string
+	names           map[reflect.Type]map[string]string // column names of fields by type, overriding their tags
+	tagPriority     []string                           // keys of the tags to consult, nil means only db
+	dialect         string                             // if not empty, the db_<dialect> tag is consulted first
+	kinds           map[reflect.Type]converter         // converters by field type
+	maxDepth        int                                // maximum depth of nested structs
+	root            reflect.Type                       // struct type whose fields are indexed, for error messages
+}
+
+// converter returns the converter for field: the one selected by its tag options, the one registered for its type,
+// or the built-in one for its type. It returns nil if there's none.
+func (o *indexOptions) converter(field reflect.StructField, tagOpts tags.Options) converter {
+	if conv := tagConverter(field, tagOpts); conv != nil {
+		return conv
+	}
+	if conv := o.kind(field.Type); conv != nil {
+		return conv
+	}
+	if field.Type.Kind() == reflect.Pointer {
+		if conv := o.kind(field.Type.Elem()); conv != nil {
+			return nullable(conv)
+		}
+	}
+	return builtinConverter(field.Type)
+}
+
+// kind returns the converter registered for t, on the Scanner or else globally, or nil if there's none.
+func (o *indexOptions) kind(t reflect.Type) converter {
+	if conv, ok := o.kinds[t]; ok {
+		return conv
+	}
+	return globalKinds.m.Load().get()[t]
+}
+
+// globalKinds holds the converters registered with RegisterGlobalKind.
+var globalKinds struct {
+	mu sync.Mutex // serializes RegisterGlobalKind
+	m  atomic.Pointer[kindMap]
+}
+
+// fieldPath returns the path of field i of the struct at cursor, from the root type, like User.Address.City. It's
+// followed by the index of the field.
+func (o *indexOptions) fieldPath(cursor []uint16, i int) string {
+	index := append(slices.Clip(cursor), uint16(i))
+	path := o.root.String()
+	t := o.root
+	for _, j := range index {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		field := t.Field(int(j))
+		path += "." + field.Name
+		t = field.Type
+	}
+	return fmt.Sprintf("%s (index %v)", path, index)
+}
+
+// structInfo describes how the fields of a struct type map to columns.
+type structInfo struct {
+	fields    structFieldIndex
+	ordered   []*fieldInfo       // fields in declaration order, for positional mapping
+	extra     []uint16           // index of the field that receives unmapped columns, nil if there is none
+	extraJSON bool               // whether the extra field receives the columns as a JSON object (the rest option)
+	nested    *nestedInfo        // field that receives the rows of a child query, nil if there is none
+	rownum    []uint16           // index of the field that receives the row number, nil if there is none
+	positions map[int]*fieldInfo // fields tagged with the position of their column, like `db:"#0"`
+	fromCtx   []*fieldInfo       // fields tagged with ctx, these are resolved from the context if their column is absent
+}
+
+// nestedInfo describes a slice field that is filled by [Scanner.ScanNested].
+type nestedInfo struct {
+	index     []uint16
+	parentKey string // column of the parent key
+	childKey  string // column of the child that refers to the parent key
+}
+
+func newStructInfo(t reflect.Type, opts *indexOptions) *structInfo {
+	x := &structInfo{
+		fields: make(structFieldIndex, t.NumField()),
+	}
+	ambiguous := make(map[string]struct{})
+	opts.root = t
+	x.fill(opts, ambiguous, t, nil, "", nil)
+	for name := range ambiguous {
+		delete(x.fields, name)
+	}
+	// Keep the fields that weren't shadowed or ambiguous.
+	ordered := x.ordered[:0]
+	for _, y := range x.ordered {
+		if x.fields[y.name] == y {
+			ordered = append(ordered, y)
+		}
+	}
+	x.ordered = ordered
+	fromCtx := x.fromCtx[:0]
+	for _, y := range x.fromCtx {
+		if x.fields[y.name] == y {
+			fromCtx = append(fromCtx, y)
+		}
+	}
+	x.fromCtx = fromCtx
+	return x
+}
+
+// fill adds the fields of t to x. It follows Go's rules for promoted fields: a shallower field shadows deeper
+// fields with the same name. Names of fields at the same depth conflict and are added to ambiguous, these must be
+// removed from x after filling. If t is the struct of a pointer field, parent is the index of that field.
+func (x *structInfo) fill(opts *indexOptions, ambiguous map[string]struct{}, t reflect.Type, cursor []uint16, prefix string, parent []uint16) {
 	numField := t.NumField()
 	for i := 0; i < numField; i++ {
 		field := t.Field(i)
-		fieldName := field.Tag.Get("db")
+		fieldName, tagOpts := tags.Parse(tags.Lookup(field, opts.dialect, opts.tagPriority))
+		if name, ok := opts.names[t][field.Name]; ok {
+			fieldName = name
+		}
 		if fieldName == "-" {
 			continue // skip
 		}
 		if field.Anonymous {
-			if kind := field.Type.Kind(); kind == reflect.Pointer {
+			switch field.Type.Kind() {
+			case reflect.Pointer:
 				panic("cannot use embedded pointer in struct")
-			} else if kind == reflect.Struct {
+			case reflect.Struct:
 				// traverse embedded struct field
-				fillStructFieldIndex(dest, field.Type, append(cursor, uint16(i)), fieldName)
+				if len(cursor)+1 > opts.maxDepth {
+					panic(fmt.Sprintf("struct field %s exceeds the maximum depth of %d", opts.fieldPath(cursor, i), opts.maxDepth))
+				}
+				embeddedPrefix := prefix
+				if fieldName != "" {
+					embeddedPrefix += fieldName + opts.prefixSeparator
+				}
+				x.fill(opts, ambiguous, field.Type, append(cursor, uint16(i)), embeddedPrefix, parent)
+				continue // next
+			case reflect.Interface:
+				// Embedded interfaces are never mapped: they have no fields to traverse, and unlike other
+				// embedded types they're not mapped by their type name either.
+				continue // skip
 			}
-			continue // next
+			// Other embedded types are mapped like regular fields, by their type name.
 		}
-		if !field.IsExported() {
-			continue // skip
+		if parent != nil {
+			for _, opt := range []string{"setter", "nested", "rownum", "extra", "rest", "ctx"} {
+				if _, ok := tagOpts.Get(opt); ok {
+					panic(fmt.Sprintf("field %s of a pointer to struct cannot have the %s option", field.Name, opt))
+				}
+			}
 		}
-		if fieldName == "" {
-			fieldName = strings.ToLower(field.Name)
+		var setter *reflect.Method
+		if name, ok := tagOpts.Get("setter"); ok {
+			setter = lookupSetter(t, field, name)
+		} else if !field.IsExported() {
+			continue // skip
 		}
 		p := make([]uint16, len(cursor)+1)
 		copy(p, cursor)
 		p[len(cursor)] = uint16(i) // it's unlikely that a struct has more than 65536 fields.
-		dest[prefix+fieldName] = p
+		if keys, ok := tagOpts.Get("nested"); ok {
+			parentKey, childKey, ok := strings.Cut(keys, ":")
+			if !ok || field.Type.Kind() != reflect.Slice {
+				panic("nested field must be a slice tagged with nested=parentkey:childkey")
+			} else if x.nested != nil {
+				panic("cannot have more than one nested field in struct")
+			}
+			x.nested = &nestedInfo{p, parentKey, childKey}
+			continue // next
+		}
+		if tagOpts.Contains("rownum") {
+			switch field.Type.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			default:
+				panic("rownum field must be an integer")
+			}
+			if x.rownum != nil {
+				panic("cannot have more than one rownum field in struct")
+			}
+			x.rownum = p
+			continue // next
+		}
+		if tagOpts.Contains("extra") {
+			if field.Type != mapStringAnyType {
+				panic("extra field must be of type map[string]any")
+			} else if x.extra != nil {
+				panic("cannot have more than one extra field in struct")
+			}
+			x.extra = p
+			continue // next
+		}
+		if tagOpts.Contains("rest") {
+			if field.Type != mapStringAnyType && field.Type != rawMessageType {
+				panic("rest field must be of type json.RawMessage or map[string]any")
+			} else if x.extra != nil {
+				panic("cannot have more than one extra field in struct")
+			}
+			x.extra = p
+			x.extraJSON = true
+			continue // next
+		}
+		if pos, ok := strings.CutPrefix(fieldName, "#"); ok {
+			n, err := strconv.Atoi(pos)
+			if err != nil || n < 0 {
+				panic(fmt.Sprintf("field %s has an invalid column position %q", field.Name, fieldName))
+			} else if x.positions[n] != nil {
+				panic(fmt.Sprintf("cannot have more than one field for column position %d in struct", n))
+			}
+			if x.positions == nil {
+				x.positions = make(map[int]*fieldInfo)
+			}
+			x.positions[n] = &fieldInfo{
+				name:   fieldName,
+				index:  p,
+				setter: setter,
+				conv:   opts.converter(field, tagOpts),
+				parent: parent,
+			}
+			continue // next
+		}
+		if fieldName != "" && parent == nil && field.Type.Kind() == reflect.Pointer && !isScalar(field.Type) &&
+			opts.converter(field, tagOpts) == nil {
+			// traverse the struct of a tagged pointer field, it's allocated when one of its columns isn't NULL
+			if len(cursor)+1 > opts.maxDepth {
+				panic(fmt.Sprintf("struct field %s exceeds the maximum depth of %d", opts.fieldPath(cursor, i), opts.maxDepth))
+			}
+			sep := opts.prefixSeparator
+			if sep == "" {
+				sep = "_"
+			}
+			x.fill(opts, ambiguous, field.Type.Elem(), p, prefix+fieldName+sep, p)
+			continue // next
+		}
+		if fieldName == "" {
+			fieldName = strings.ToLower(field.Name)
+		}
+		fieldName = prefix + fieldName
+		if y, ok := x.fields[fieldName]; ok {
+			if len(y.index) < len(p) {
+				continue // shadowed by a shallower field
+			} else if len(y.index) == len(p) {
+				if sameStruct(y.index, p) && y.setter == nil && setter == nil {
+					// Fields of the same struct can only share a name through their tags, the column is copied
+					// into all of them.
+					if ft := fieldTypeByIndex(t, p[len(p)-1:]); ft != fieldTypeByIndex(t, y.index[len(y.index)-1:]) {
+						panic(fmt.Sprintf("fields for column %q must be of the same type", fieldName))
+					}
+					y.copies = append(y.copies, p)
+					continue
+				}
+				ambiguous[fieldName] = struct{}{}
+				continue
+			}
+			delete(ambiguous, fieldName)
+		}
+		y := &fieldInfo{
+			name:   fieldName,
+			index:  p,
+			setter: setter,
+			conv:   opts.converter(field, tagOpts),
+			parent: parent,
+		}
+		x.fields[fieldName] = y
+		x.ordered = append(x.ordered, y)
+		if tagOpts.Contains("ctx") {
+			if setter != nil {
+				panic(fmt.Sprintf("ctx field %s cannot have a setter", field.Name))
+			}
+			x.fromCtx = append(x.fromCtx, y)
+		}
 	}
 }
+
+// sameStruct reports whether the fields with the given indexes are in the same struct.
+func sameStruct(a, b []uint16) bool {
+	return slices.Equal(a[:len(a)-1], b[:len(b)-1])
+}
+
+// lookupSetter returns the setter method with the given name of the struct t for field.
+// The setter must have a pointer receiver, take a single argument of the field's type, and return nothing or an error.
+func lookupSetter(t reflect.Type, field reflect.StructField, name string) *reflect.Method {
+	m, ok := reflect.PointerTo(t).MethodByName(name)
+	if !ok {
+		panic(fmt.Sprintf("setter %s of field %s not found on *%s", name, field.Name, t))
+	}
+	mt := m.Type
+	if mt.NumIn() != 2 || mt.In(1) != field.Type || mt.NumOut() > 1 || (mt.NumOut() == 1 && mt.Out(0) != errorType) {
+		panic(fmt.Sprintf("setter %s of field %s must be of type func(%s) or func(%s) error", name, field.Name, field.Type, field.Type))
+	}
+	return &m
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+var (
+	mapStringAnyType = reflect.TypeOf(map[string]any(nil))
+	rawMessageType   = reflect.TypeOf(json.RawMessage(nil))
+)
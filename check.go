@@ -0,0 +1,256 @@
+package sqlz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// CheckTypes checks whether the columns described by cts can be scanned into dest. dest can be anything Scan accepts,
+// or a struct value. It returns an error describing every column that has no corresponding struct field, or whose
+// scan type (as reported by the driver) is incompatible with the type of its field. Columns are mapped to fields
+// the same way Scan maps them. Fields that implement [sql.Scanner], or have a converter, are assumed to be compatible
+// with any column.
+func (s *Scanner) CheckTypes(dest any, cts []*sql.ColumnType) error {
+	t, err := destStructType(dest)
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(cts))
+	for i, ct := range cts {
+		columns[i] = ct.Name()
+	}
+	info := s.structInfo(t)
+	opts := s.options()
+	opts.ignoreUnknownColumns, opts.logger = true, nil // unknown columns are reported below, all at once
+	fields, err := s.columnFields(t, info, columns, &opts, nil)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, ct := range cts {
+		x := fields[i]
+		if x == nil {
+			if info.extra == nil && !s.IgnoreUnknownColumns && !opts.denied(ct.Name()) {
+				errs = append(errs, fmt.Errorf("sqlz: missing field mapping for column %q", ct.Name()))
+			}
+			continue
+		}
+		field := fieldTypeByIndex(t, x.index)
+		if st := ct.ScanType(); x.conv == nil && !compatibleScanType(st, field) {
+			errs = append(errs, fmt.Errorf("sqlz: column %q of type %s is incompatible with field of type %s", ct.Name(), st, field))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Scannable returns an error describing why dest can't be scanned into, or nil if it can. It checks the shape of
+// dest, like [Scanner.ScanChecked] does, and the struct it holds: misuse, like an embedded pointer or an invalid tag
+// option, is reported by an error that wraps [ErrMisuse]. A struct without scannable fields is reported too.
+func (s *Scanner) Scannable(dest any) (err error) {
+	if err := checkDest(dest); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(dest)
+	switch t.Kind() {
+	case reflect.Func:
+		t = t.In(0).Elem()
+	case reflect.Chan:
+		t = t.Elem()
+	case reflect.Pointer:
+		if t = t.Elem(); t.Kind() == reflect.Slice {
+			if t = t.Elem(); s.isScalar(t) {
+				return nil
+			}
+		}
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = misuseError(r)
+		}
+	}()
+	if info := s.structInfo(t); len(info.fields) == 0 && len(info.positions) == 0 && info.extra == nil {
+		return fmt.Errorf("sqlz: struct %s has no scannable fields", t)
+	}
+	return nil
+}
+
+// MappingFor returns how Scan maps the given columns to the fields of dest. dest can be anything Scan accepts,
+// or a struct value. The returned map holds the index of the corresponding field for each column, in the form
+// used by [reflect.Value.FieldByIndex]. Columns that are ignored, or captured by an extra field, are left out.
+// It returns an error if Scan would, like when a column has no corresponding field.
+func (s *Scanner) MappingFor(dest any, columns []string) (map[string][]int, error) {
+	t, err := destStructType(dest)
+	if err != nil {
+		return nil, err
+	}
+	opts := s.options()
+	fields, err := s.columnFields(t, s.structInfo(t), columns, &opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	mapping := make(map[string][]int, len(columns))
+	for i, x := range fields {
+		if x == nil {
+			continue
+		}
+		index := make([]int, len(x.index))
+		for i, v := range x.index {
+			index[i] = int(v)
+		}
+		mapping[columns[i]] = index
+	}
+	return mapping, nil
+}
+
+// Diff scans a single row from rows into a new value of the type of current, and returns the columns whose values
+// differ from the corresponding fields of current, compared with [reflect.DeepEqual]. current must be a struct or a
+// pointer to a struct. Columns are mapped to fields the same way Scan maps them. Columns without a corresponding
+// field, denied columns, and columns of unexported fields are left out.
+func (s *Scanner) Diff(current any, rows Rows) (_ []string, err error) {
+	defer s.recoverMisuse(&err)
+	cur := reflect.Indirect(reflect.ValueOf(current))
+	if cur.Kind() != reflect.Struct {
+		panic("current must be a struct or a pointer to a struct")
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	scratch := reflect.New(cur.Type())
+	if err = s.Scan(context.Background(), rows, scratch.Interface()); err != nil {
+		return nil, err
+	}
+	opts := s.options()
+	fields, err := s.columnFields(cur.Type(), s.structInfo(cur.Type()), columns, &opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for i, x := range fields {
+		if x == nil {
+			continue
+		}
+		a, b := fieldByIndex(scratch.Elem(), x.index), fieldByIndex(cur, x.index)
+		if !a.CanInterface() {
+			continue // unexported fields, like those with a setter, can't be compared
+		}
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			changed = append(changed, columns[i])
+		}
+	}
+	return changed, nil
+}
+
+// MappingFor returns how Scan maps the given columns to the fields of dest, using the global Scanner.
+// See [Scanner.MappingFor] for more details.
+func MappingFor(dest any, columns []string) (map[string][]int, error) {
+	return Default().MappingFor(dest, columns)
+}
+
+// destStructType returns the struct type of the destination dest.
+func destStructType(dest any) (reflect.Type, error) {
+	t := reflect.TypeOf(dest)
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Chan) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqlz: %T is not and does not contain a struct", dest)
+	}
+	return t, nil
+}
+
+var (
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	rawBytesType = reflect.TypeOf(sql.RawBytes(nil))
+	bytesType    = reflect.TypeOf([]byte(nil))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// nullScanTypes maps the sql.Null* types that drivers report for nullable columns to their value types.
+var nullScanTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullByte{}):    reflect.TypeOf(byte(0)),
+	reflect.TypeOf(sql.NullInt16{}):   reflect.TypeOf(int16(0)),
+	reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+	reflect.TypeOf(sql.NullString{}):  reflect.TypeOf(""),
+	reflect.TypeOf(sql.NullTime{}):    timeType,
+}
+
+func compatibleScanType(st, field reflect.Type) bool {
+	if st == nil || st.Kind() == reflect.Interface || scansItself(field) {
+		return true
+	}
+	if field.Kind() == reflect.Pointer {
+		field = field.Elem()
+		if scansItself(field) {
+			return true
+		}
+	}
+	if st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+	if vt, ok := nullScanTypes[st]; ok {
+		st = vt
+	} else if st == rawBytesType {
+		st = bytesType
+	}
+	if sc, fc := scanTypeClass(st), scanTypeClass(field); sc != classNone || fc != classNone {
+		return sc == fc
+	}
+	return st.AssignableTo(field) || (st.Kind() == field.Kind() && st.ConvertibleTo(field))
+}
+
+// scansItself reports whether a pointer to t implements [sql.Scanner]. Fields that implement [Decoder] have a
+// converter, so they aren't checked.
+func scansItself(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(scannerType)
+}
+
+// Classes of types that can be converted between when scanning, see scanTypeClass.
+const (
+	classNone = iota
+	classBool
+	classNumber
+	classText
+)
+
+// scanTypeClass returns the class of types that t can be converted between when scanning, or classNone if there's
+// none.
+func scanTypeClass(t reflect.Type) int {
+	switch t.Kind() {
+	case reflect.Bool:
+		return classBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return classNumber
+	case reflect.String:
+		return classText
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return classText
+		}
+	}
+	return classNone
+}
+
+// fieldTypeByIndex returns the type of the nested field of t with the given index. Pointers to structs on the way
+// are dereferenced.
+func fieldTypeByIndex(t reflect.Type, index []uint16) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		t = t.Field(int(i)).Type
+	}
+	return t
+}
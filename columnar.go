@@ -0,0 +1,66 @@
+package sqlz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// ScanColumnar scans the result set from rows column by column: the values of each column are appended to the
+// slice that the corresponding dest points to.
+//
+// There must be exactly one dest per column, in the order of the columns, otherwise ScanColumnar returns an error.
+// Every dest must be a pointer to a slice of a type that the column can be scanned into. Like Scan, it stops when
+// the context is canceled, see also [Scanner.PartialOnCancel].
+func (s *Scanner) ScanColumnar(ctx context.Context, rows Rows, dests ...any) (err error) {
+	defer s.recoverMisuse(&err)
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if len(dests) != len(columns) {
+		return fmt.Errorf("sqlz: ScanColumnar requires one destination per column, got %d for %d columns", len(dests), len(columns))
+	}
+	var (
+		targets = make([]reflect.Value, len(dests))
+		elems   = make([]reflect.Value, len(dests))
+		origLen = make([]int, len(dests))
+		p       = &plan{values: make([]any, len(dests)), ownRawBytes: true}
+	)
+	for i, dest := range dests {
+		v := reflect.ValueOf(dest)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Slice {
+			panic("dests must be pointers to slices")
+		}
+		targets[i] = v.Elem()
+		origLen[i] = targets[i].Len()
+		elems[i] = reflect.New(targets[i].Type().Elem()).Elem()
+		p.values[i] = scanTarget(elems[i])
+		if rb, ok := p.values[i].(*sql.RawBytes); ok {
+			p.rawBytes = append(p.rawBytes, rb)
+		}
+	}
+	err = scanRows(ctx, rows, p, reflect.Value{}, func() error {
+		for i, target := range targets {
+			target.Set(reflect.Append(target, elems[i]))
+			elems[i].SetZero() // the elems are reset here, since there's more than one
+		}
+		return nil
+	})
+	if err != nil && err == ctx.Err() && !s.PartialOnCancel {
+		for i, target := range targets {
+			for j := origLen[i]; j < target.Len(); j++ {
+				target.Index(j).SetZero()
+			}
+			target.SetLen(origLen[i])
+		}
+	}
+	return err
+}
+
+// ScanColumnar scans the result set from rows column by column into dests, using the global Scanner.
+// See [Scanner.ScanColumnar] for more details.
+func ScanColumnar(ctx context.Context, rows Rows, dests ...any) error {
+	return Default().ScanColumnar(ctx, rows, dests...)
+}
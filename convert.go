@@ -0,0 +1,452 @@
+package sqlz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semrekkers/sqlz/internal/tags"
+)
+
+// A converter stores a value from the database (src) into dst. It's used for field types that database/sql
+// can't convert to by itself.
+type converter func(src any, dst reflect.Value) error
+
+// convertValue is a scan destination that converts values using a converter.
+type convertValue struct {
+	dst  reflect.Value
+	conv converter
+}
+
+// Scan implements the [sql.Scanner] interface.
+func (v *convertValue) Scan(src any) error {
+	return v.conv(src, v.dst)
+}
+
+// scanTarget returns the scan destination for v, a value that isn't a struct field, like a scalar slice element.
+// Struct fields have their converter resolved when their struct is indexed.
+func scanTarget(v reflect.Value) any {
+	if conv := builtinConverter(v.Type()); conv != nil {
+		return &convertValue{v, conv}
+	}
+	return v.Addr().Interface()
+}
+
+// builtinConverter returns the built-in converter for values of type t, or pointers to them, or nil if there's none.
+func builtinConverter(t reflect.Type) converter {
+	if conv := converterFor(t); conv != nil {
+		return conv
+	}
+	if t.Kind() == reflect.Pointer {
+		if conv := converterFor(t.Elem()); conv != nil {
+			return nullable(conv)
+		}
+	}
+	return nil
+}
+
+func converterFor(t reflect.Type) converter {
+	if reflect.PointerTo(t).Implements(scannerType) {
+		return nil
+	}
+	if reflect.PointerTo(t).Implements(decoderType) {
+		return convertDecoder
+	}
+	switch t {
+	case bigRatType, bigFloatType, bigIntType:
+		return convertBig
+	case ipType:
+		return convertIP
+	case addrType:
+		return convertAddr
+	case urlType:
+		return convertURL
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return convertBool
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && t != bytesType && t != rawBytesType {
+			return convertBytes
+		}
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return convertByteArray
+		}
+	}
+	return nil
+}
+
+// tagConverter returns the converter selected by the tag options of field, or nil if there's none.
+// The epoch and epoch_ms options scan Unix timestamps in seconds or milliseconds into time.Time fields.
+// The json option decodes JSON into fields of any type, the hstore option parses Postgres hstores into maps, and the
+// pgarray option parses Postgres arrays into slices.
+func tagConverter(field reflect.StructField, opts tags.Options) converter {
+	var conv, toEpoch converter
+	switch {
+	case opts.Contains("json"):
+		return convertJSON
+	case opts.Contains("pgarray"):
+		if field.Type.Kind() != reflect.Slice || !isPgArrayElem(field.Type.Elem()) {
+			panic(fmt.Sprintf("pgarray field %s must be a slice of strings, numbers or bools", field.Name))
+		}
+		return convertPgArray
+	case opts.Contains("hstore"):
+		if field.Type != mapStringStringType && field.Type != mapStringStringPtrType {
+			panic(fmt.Sprintf("hstore field %s must be of type map[string]string or map[string]*string", field.Name))
+		}
+		return convertHstore
+	case opts.Contains("epoch"):
+		conv, toEpoch = convertEpoch(time.Second), convertToEpoch(time.Second)
+	case opts.Contains("epoch_ms"):
+		conv, toEpoch = convertEpoch(time.Millisecond), convertToEpoch(time.Millisecond)
+	default:
+		return nil
+	}
+	switch field.Type {
+	case timeType:
+		return conv
+	case reflect.PointerTo(timeType):
+		return nullable(conv)
+	case int64Type:
+		return toEpoch
+	case reflect.PointerTo(int64Type):
+		return nullable(toEpoch)
+	}
+	panic(fmt.Sprintf("epoch field %s must be of type time.Time, int64 or a pointer to one", field.Name))
+}
+
+// nullable returns a converter for pointers to the type that conv converts to. NULL is stored as nil.
+func nullable(conv converter) converter {
+	return func(src any, dst reflect.Value) error {
+		if src == nil {
+			dst.SetZero()
+			return nil
+		}
+		v := reflect.New(dst.Type().Elem())
+		if err := conv(src, v.Elem()); err != nil {
+			return err
+		}
+		dst.Set(v)
+		return nil
+	}
+}
+
+// zeroOnNull returns a converter that stores NULL as the zero value, and other values using conv.
+func zeroOnNull(conv converter) converter {
+	return func(src any, dst reflect.Value) error {
+		if src == nil {
+			dst.SetZero()
+			return nil
+		}
+		return conv(src, dst)
+	}
+}
+
+var decoderType = reflect.TypeOf((*Decoder)(nil)).Elem()
+
+// convertDecoder passes the value to the DecodeSQL method of dst, which must implement [Decoder].
+func convertDecoder(src any, dst reflect.Value) error {
+	return dst.Addr().Interface().(Decoder).DecodeSQL(src)
+}
+
+// convertBool converts integers, bit values and the strings accepted by [strconv.ParseBool] to a bool.
+func convertBool(src any, dst reflect.Value) error {
+	var b bool
+	switch x := src.(type) {
+	case bool:
+		b = x
+	case int64:
+		b = x != 0
+	case float64:
+		b = x != 0
+	case string:
+		var err error
+		if b, err = strconv.ParseBool(x); err != nil {
+			return fmt.Errorf("sqlz: converting %q to a bool: %w", x, err)
+		}
+	case []byte:
+		var err error
+		if b, err = strconv.ParseBool(string(x)); err != nil {
+			if len(x) != 1 {
+				return fmt.Errorf("sqlz: converting %q to a bool: %w", x, err)
+			}
+			b = x[0] != 0 // BIT(1)
+		}
+	case nil:
+		return fmt.Errorf("sqlz: converting NULL to %s is unsupported", dst.Type())
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	dst.SetBool(b)
+	return nil
+}
+
+// convertBytes converts strings and byte slices to named byte slice types, like json.RawMessage. database/sql
+// rejects string values and NULL for these types, while drivers commonly return text columns as strings. Byte
+// slices passed to a converter are owned by the driver, so they're copied.
+func convertBytes(src any, dst reflect.Value) error {
+	switch x := src.(type) {
+	case []byte:
+		dst.SetBytes(bytes.Clone(x))
+	case string:
+		dst.SetBytes([]byte(x))
+	case nil:
+		dst.SetZero()
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	return nil
+}
+
+// convertByteArray converts byte slices of the same length to byte arrays, like [16]byte for UUIDs. Other byte
+// slices and strings are decoded as hex, ignoring dashes, so the text form of a UUID converts to [16]byte.
+// NULL is stored as the zero value.
+func convertByteArray(src any, dst reflect.Value) error {
+	var b []byte
+	switch x := src.(type) {
+	case []byte:
+		if len(x) == dst.Len() {
+			reflect.Copy(dst, reflect.ValueOf(x))
+			return nil
+		}
+		b = x
+	case string:
+		b = []byte(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	decoded, err := hex.DecodeString(strings.ReplaceAll(string(b), "-", ""))
+	if err != nil || len(decoded) != dst.Len() {
+		return fmt.Errorf("sqlz: converting %q to %s: length or syntax mismatch", b, dst.Type())
+	}
+	reflect.Copy(dst, reflect.ValueOf(decoded))
+	return nil
+}
+
+var stringType = reflect.TypeOf("")
+
+// convertString returns a converter to strings that passes byte slices through sanitize. Other values are
+// formatted like database/sql does.
+func convertString(sanitize func([]byte) string) converter {
+	return func(src any, dst reflect.Value) error {
+		var s string
+		switch x := src.(type) {
+		case []byte:
+			s = sanitize(x)
+		case string:
+			s = x
+		case int64:
+			s = strconv.FormatInt(x, 10)
+		case float64:
+			s = strconv.FormatFloat(x, 'g', -1, 64)
+		case bool:
+			s = strconv.FormatBool(x)
+		case time.Time:
+			s = x.Format(time.RFC3339Nano)
+		case nil:
+			return fmt.Errorf("sqlz: converting NULL to %s is unsupported", dst.Type())
+		default:
+			return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+		}
+		dst.SetString(s)
+		return nil
+	}
+}
+
+// convertJSON decodes JSON documents, as string or []byte, with [json.Unmarshal]. NULL is stored as the zero value.
+func convertJSON(src any, dst reflect.Value) error {
+	var data []byte
+	switch x := src.(type) {
+	case []byte:
+		data = x
+	case string:
+		data = []byte(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	dst.SetZero() // json.Unmarshal merges into existing maps and structs
+	if err := json.Unmarshal(data, dst.Addr().Interface()); err != nil {
+		return fmt.Errorf("sqlz: decoding JSON into %s: %w", dst.Type(), err)
+	}
+	return nil
+}
+
+// convertEpoch returns a converter from integer Unix timestamps, in the given unit, to time.Time.
+func convertEpoch(unit time.Duration) converter {
+	perSecond := int64(time.Second / unit)
+	return func(src any, dst reflect.Value) error {
+		var v int64
+		switch x := src.(type) {
+		case int64:
+			v = x
+		case float64:
+			v = int64(x)
+		case string:
+			var err error
+			if v, err = strconv.ParseInt(x, 10, 64); err != nil {
+				return fmt.Errorf("sqlz: converting %q to a Unix timestamp: %w", x, err)
+			}
+		case []byte:
+			var err error
+			if v, err = strconv.ParseInt(string(x), 10, 64); err != nil {
+				return fmt.Errorf("sqlz: converting %q to a Unix timestamp: %w", x, err)
+			}
+		case nil:
+			return fmt.Errorf("sqlz: converting NULL to %s is unsupported", dst.Type())
+		default:
+			return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+		}
+		dst.Set(reflect.ValueOf(time.Unix(v/perSecond, v%perSecond*int64(unit))))
+		return nil
+	}
+}
+
+var int64Type = reflect.TypeOf(int64(0))
+
+// convertToEpoch returns a converter from timestamps to int64 Unix timestamps in the given unit. Integers are
+// assumed to be Unix timestamps in that unit already.
+func convertToEpoch(unit time.Duration) converter {
+	return func(src any, dst reflect.Value) error {
+		switch x := src.(type) {
+		case time.Time:
+			dst.SetInt(x.Unix()*int64(time.Second/unit) + int64(x.Nanosecond())/int64(unit))
+		case int64:
+			dst.SetInt(x)
+		case nil:
+			return fmt.Errorf("sqlz: converting NULL to %s is unsupported", dst.Type())
+		default:
+			return fmt.Errorf("sqlz: unsupported Scan, converting %T to a Unix timestamp", src)
+		}
+		return nil
+	}
+}
+
+var (
+	bigRatType   = reflect.TypeOf(big.Rat{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+)
+
+// convertBig converts numbers, and their string representation, to a big.Rat, big.Float or big.Int.
+// NULL is stored as zero.
+func convertBig(src any, dst reflect.Value) error {
+	var s string
+	switch x := src.(type) {
+	case int64:
+		s = strconv.FormatInt(x, 10)
+	case float64:
+		s = strconv.FormatFloat(x, 'g', -1, 64)
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	var ok bool
+	switch v := dst.Addr().Interface().(type) {
+	case *big.Rat:
+		_, ok = v.SetString(s)
+	case *big.Float:
+		_, ok = v.SetString(s)
+	case *big.Int:
+		_, ok = v.SetString(s, 10)
+	}
+	if !ok {
+		return fmt.Errorf("sqlz: converting %q to %s: invalid syntax", s, dst.Type())
+	}
+	return nil
+}
+
+var (
+	ipType   = reflect.TypeOf(net.IP(nil))
+	addrType = reflect.TypeOf(netip.Addr{})
+)
+
+// convertIP converts the text form of an IPv4 or IPv6 address to a net.IP. NULL is stored as nil.
+func convertIP(src any, dst reflect.Value) error {
+	var s string
+	switch x := src.(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fmt.Errorf("sqlz: converting %q to %s: invalid IP address", s, dst.Type())
+	}
+	dst.Set(reflect.ValueOf(ip))
+	return nil
+}
+
+// convertAddr converts the text form of an IPv4 or IPv6 address to a netip.Addr. NULL is stored as the zero Addr,
+// which is invalid.
+func convertAddr(src any, dst reflect.Value) error {
+	var s string
+	switch x := src.(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return fmt.Errorf("sqlz: converting %q to %s: %w", s, dst.Type(), err)
+	}
+	dst.Set(reflect.ValueOf(addr))
+	return nil
+}
+
+var urlType = reflect.TypeOf(url.URL{})
+
+// convertURL parses a URL with [url.Parse]. NULL is stored as the zero URL.
+func convertURL(src any, dst reflect.Value) error {
+	var s string
+	switch x := src.(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("sqlz: converting %q to %s: %w", s, dst.Type(), err)
+	}
+	dst.Set(reflect.ValueOf(*u))
+	return nil
+}
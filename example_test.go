@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"reflect"
 	"time"
 
 	"github.com/semrekkers/sqlz"
@@ -75,3 +76,80 @@ func ExampleScan_chan() {
 		fmt.Println("found active user:", user.ID)
 	}
 }
+
+type Setting struct {
+	Key   string
+	Value string
+}
+
+func ExampleMustSelect() {
+	// MustSelect is appropriate at startup: the application can't run without its settings.
+	settings := sqlz.MustSelect[Setting](ctx, db, "SELECT key, value FROM settings")
+	for _, s := range settings {
+		fmt.Println(s.Key, "=", s.Value)
+	}
+}
+
+func ExampleMustGet() {
+	// In a request handler, a missing or failing row is an expected error. Use GetPtr instead of MustGet,
+	// so the error can be handled instead of crashing the server.
+	user, err := sqlz.GetPtr[User](ctx, db, "SELECT * FROM users WHERE id = $1", 123)
+	if err != nil {
+		log.Println("query user:", err)
+		return
+	}
+	if user == nil {
+		log.Println("user not found")
+		return
+	}
+
+	// At startup, MustGet keeps loading required rows short.
+	admin := sqlz.MustGet[User](ctx, db, "SELECT * FROM users WHERE name = $1", "admin")
+	log.Println(user, admin)
+}
+
+// memRows is an in-memory implementation of sqlz.Rows. It stores the values as is, a real implementation converts
+// them like database/sql does.
+type memRows struct {
+	columns []string
+	values  [][]any
+	row     int
+}
+
+func (r *memRows) Columns() ([]string, error) { return r.columns, nil }
+func (r *memRows) Err() error                 { return nil }
+
+func (r *memRows) Next() bool {
+	r.row++
+	return r.row <= len(r.values)
+}
+
+func (r *memRows) Scan(dest ...any) error {
+	for i, v := range r.values[r.row-1] {
+		if s, ok := dest[i].(sql.Scanner); ok {
+			if err := s.Scan(v); err != nil {
+				return err
+			}
+			continue
+		}
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func ExampleRows() {
+	rows := &memRows{
+		columns: []string{"key", "value"},
+		values: [][]any{
+			{"theme", "dark"},
+			{"lang", "en"},
+		},
+	}
+
+	var settings []Setting
+	if err := sqlz.Scan(context.Background(), rows, &settings); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(settings)
+	// Output: [{theme dark} {lang en}]
+}
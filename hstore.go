@@ -0,0 +1,141 @@
+package sqlz
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	mapStringStringType    = reflect.TypeOf(map[string]string(nil))
+	mapStringStringPtrType = reflect.TypeOf(map[string]*string(nil))
+)
+
+// convertHstore parses the text form of a Postgres hstore, like "a"=>"1", "b"=>NULL, into a map[string]string or
+// map[string]*string. NULL values are stored as "" or nil respectively. A NULL hstore is stored as a nil map.
+func convertHstore(src any, dst reflect.Value) error {
+	var s string
+	switch x := src.(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	m, err := parseHstore(s)
+	if err != nil {
+		return fmt.Errorf("sqlz: converting %q to an hstore: %w", s, err)
+	}
+	if dst.Type() == mapStringStringPtrType {
+		dst.Set(reflect.ValueOf(m))
+		return nil
+	}
+	mm := make(map[string]string, len(m))
+	for k, v := range m {
+		if v != nil {
+			mm[k] = *v
+		} else {
+			mm[k] = ""
+		}
+	}
+	dst.Set(reflect.ValueOf(mm))
+	return nil
+}
+
+// parseHstore parses the text form of an hstore.
+func parseHstore(s string) (map[string]*string, error) {
+	var (
+		m = make(map[string]*string)
+		p = hstoreParser{s: s}
+	)
+	for {
+		p.skipSpace()
+		if p.done() {
+			return m, nil
+		}
+		key, quoted, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		if !quoted && strings.EqualFold(key, "NULL") {
+			return nil, errors.New("key is NULL")
+		}
+		p.skipSpace()
+		if !strings.HasPrefix(p.s[p.i:], "=>") {
+			return nil, fmt.Errorf("expected => at offset %d", p.i)
+		}
+		p.i += 2
+		p.skipSpace()
+		value, quoted, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		if !quoted && strings.EqualFold(value, "NULL") {
+			m[key] = nil
+		} else {
+			m[key] = &value
+		}
+		p.skipSpace()
+		if p.done() {
+			return m, nil
+		}
+		if p.s[p.i] != ',' {
+			return nil, fmt.Errorf("expected , at offset %d", p.i)
+		}
+		p.i++
+	}
+}
+
+// hstoreParser holds the state of parseHstore.
+type hstoreParser struct {
+	s string
+	i int
+}
+
+func (p *hstoreParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *hstoreParser) skipSpace() {
+	for !p.done() && strings.IndexByte(" \t\n\r", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// token returns the next key or value, and whether it was quoted. Quoted tokens may contain backslash escapes.
+func (p *hstoreParser) token() (string, bool, error) {
+	if p.done() {
+		return "", false, errors.New("unexpected end of input")
+	}
+	if p.s[p.i] != '"' {
+		start := p.i
+		for !p.done() && strings.IndexByte(" \t\n\r=>,\"", p.s[p.i]) < 0 {
+			p.i++
+		}
+		if p.i == start {
+			return "", false, fmt.Errorf("unexpected %q at offset %d", p.s[p.i], p.i)
+		}
+		return p.s[start:p.i], false, nil
+	}
+	var b strings.Builder
+	for p.i++; !p.done(); p.i++ {
+		switch c := p.s[p.i]; c {
+		case '\\':
+			if p.i++; p.done() {
+				return "", false, errors.New("unexpected end of input")
+			}
+			b.WriteByte(p.s[p.i])
+		case '"':
+			p.i++
+			return b.String(), true, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", false, errors.New("unterminated quoted string")
+}
@@ -0,0 +1,133 @@
+package scantest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// Query returns the result set of the given columns and values as real [sql.Rows]. The values are served by an
+// in-memory driver, so they are converted by database/sql just like values from a real driver. The rows and the
+// underlying database are closed when the test ends.
+func Query(tb testing.TB, columns []string, values ...[]driver.Value) *sql.Rows {
+	tb.Helper()
+	rows, err := Open(tb, columns, values...).Query("")
+	if err != nil {
+		tb.Fatal("scantest: query:", err)
+	}
+	tb.Cleanup(func() {
+		rows.Close()
+	})
+	return rows
+}
+
+// Open returns a database whose queries all return the result set of the given columns and values.
+// The database is closed when the test ends.
+func Open(tb testing.TB, columns []string, values ...[]driver.Value) *sql.DB {
+	return open(tb, connector{columns: columns, values: values})
+}
+
+// Repeat returns a database whose queries all return a result set of the given columns that repeats row endlessly.
+// It serves any number of rows without holding them. The database is closed when the test ends.
+func Repeat(tb testing.TB, columns []string, row []driver.Value) *sql.DB {
+	return open(tb, connector{columns: columns, values: [][]driver.Value{row}, repeat: true})
+}
+
+func open(tb testing.TB, c connector) *sql.DB {
+	db := sql.OpenDB(c)
+	tb.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
+
+type connector struct {
+	columns []string
+	values  [][]driver.Value
+	repeat  bool // whether the values are served over and over
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return conn(c), nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return memDriver{}
+}
+
+type memDriver struct{}
+
+func (memDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("scantest: memDriver can only be used through a connector")
+}
+
+type conn connector
+
+func (c conn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &memRows{
+		columns: c.columns,
+		values:  c.values,
+		repeat:  c.repeat,
+		buf:     make([][]byte, len(c.columns)),
+	}, nil
+}
+
+func (conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("scantest: Prepare is not supported")
+}
+
+func (conn) Close() error {
+	return nil
+}
+
+func (conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("scantest: Begin is not supported")
+}
+
+type memRows struct {
+	columns []string
+	values  [][]driver.Value
+	repeat  bool
+	i       int
+	buf     [][]byte
+}
+
+func (r *memRows) Columns() []string {
+	return r.columns
+}
+
+// ColumnTypeScanType returns the type of the first non-nil value in the column.
+func (r *memRows) ColumnTypeScanType(index int) reflect.Type {
+	for _, row := range r.values {
+		if v := row[index]; v != nil {
+			return reflect.TypeOf(v)
+		}
+	}
+	return reflect.TypeOf((*any)(nil)).Elem()
+}
+
+func (r *memRows) Close() error {
+	return nil
+}
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.values) && r.repeat {
+		r.i = 0
+	} else if r.i >= len(r.values) {
+		return io.EOF
+	}
+	for i, v := range r.values[r.i] {
+		if b, ok := v.([]byte); ok {
+			// Reuse the buffer for every row, like most real drivers do.
+			r.buf[i] = append(r.buf[i][:0], b...)
+			v = r.buf[i]
+		}
+		dest[i] = v
+	}
+	r.i++
+	return nil
+}
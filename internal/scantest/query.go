@@ -1,6 +1,7 @@
 package scantest
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
@@ -114,6 +115,8 @@ func scanv[T any](name string, dest any, v T) error {
 		*x = v
 	case *any:
 		*x = v
+	case sql.Scanner:
+		return x.Scan(v)
 	default:
 		return fmt.Errorf("scantest: scan %s: cannot convert %T to %T", name, v, dest)
 	}
@@ -0,0 +1,60 @@
+// Package tags parses the struct tags that map fields to columns. It's shared by sqlz and sqlztest, so both read
+// the tags the same way.
+package tags
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Options are the comma-separated options that follow the name in a `db` tag.
+type Options string
+
+// Parse splits a `db` tag into its name and options.
+func Parse(tag string) (string, Options) {
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, Options(opts)
+}
+
+// Contains reports whether opt is one of the options.
+func (o Options) Contains(opt string) bool {
+	for o != "" {
+		name, rest, _ := strings.Cut(string(o), ",")
+		if name == opt {
+			return true
+		}
+		o = Options(rest)
+	}
+	return false
+}
+
+// Get returns the value of the option with the given key, in the form key=value.
+func (o Options) Get(key string) (string, bool) {
+	for o != "" {
+		opt, rest, _ := strings.Cut(string(o), ",")
+		if k, v, ok := strings.Cut(opt, "="); ok && k == key {
+			return v, true
+		}
+		o = Options(rest)
+	}
+	return "", false
+}
+
+// Lookup returns the tag of field that's consulted: the db_<dialect> tag if dialect isn't empty and field has it,
+// or else the first tag of the keys in priority that field has. A nil priority means only db.
+func Lookup(field reflect.StructField, dialect string, priority []string) string {
+	if dialect != "" {
+		if tag := field.Tag.Get("db_" + dialect); tag != "" {
+			return tag
+		}
+	}
+	if priority == nil {
+		return field.Tag.Get("db")
+	}
+	for _, key := range priority {
+		if tag := field.Tag.Get(key); tag != "" {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package sqlz
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// ScanToJSON scans the result set into values of type T and writes them to w as a JSON array, encoded with
+// [json.Marshal]. T must be a struct type, or a scalar type if the result set has exactly one column.
+// It scans and writes one row at a time, so memory usage doesn't grow with the size of the result set.
+// If s is nil, the global Scanner is used. See [Scanner.Scan] for more details.
+//
+// ScanToJSON stops when the context is canceled. If it returns an error, an incomplete array may have been written to w.
+func ScanToJSON[T any](ctx context.Context, s *Scanner, rows Rows, w io.Writer) (err error) {
+	if s == nil {
+		s = Default()
+	}
+	defer s.recoverMisuse(&err)
+	var (
+		opts = s.options()
+		v    T
+		elem = reflect.ValueOf(&v).Elem()
+	)
+	opts.ctx = ctx
+	p, err := s.mapDest(elem, rows, &opts)
+	if err != nil {
+		return err
+	}
+	defer p.release()
+	p.ownRawBytes = true
+	if _, err = io.WriteString(w, "["); err != nil {
+		return err
+	}
+	sep := ""
+	err = scanRows(ctx, rows, p, elem, func() error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, sep); err != nil {
+			return err
+		}
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+		sep = ","
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
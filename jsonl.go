@@ -0,0 +1,111 @@
+package sqlz
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// JSONLinesRows returns Rows that read newline-delimited JSON objects from r, one row per object.
+// The values of the given columns are taken from the object keys with the same name, missing keys are NULL.
+//
+// Values are decoded with [json.Unmarshal] into the scan destinations. Destinations that implement [sql.Scanner]
+// receive the value as a driver would return it: an int64 or float64 for numbers, a string, a bool, nil for null,
+// or the raw JSON as []byte for objects and arrays. Like with a driver, scanning NULL into a destination that
+// can't hold it, like an *int, fails.
+func JSONLinesRows(r io.Reader, columns []string) Rows {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonLinesRows{
+		dec:     dec,
+		columns: columns,
+	}
+}
+
+type jsonLinesRows struct {
+	dec     *json.Decoder
+	columns []string
+	row     map[string]json.RawMessage
+	err     error
+}
+
+func (r *jsonLinesRows) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+func (r *jsonLinesRows) Err() error {
+	return r.err
+}
+
+func (r *jsonLinesRows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	r.row = nil
+	if err := r.dec.Decode(&r.row); err != nil {
+		if !errors.Is(err, io.EOF) {
+			r.err = err
+		}
+		return false
+	}
+	return true
+}
+
+func (r *jsonLinesRows) Scan(dest ...any) error {
+	if r.row == nil {
+		return errors.New("sqlz: Scan called without calling Next")
+	}
+	if len(dest) != len(r.columns) {
+		return fmt.Errorf("sqlz: expected %d destination arguments in Scan, not %d", len(r.columns), len(dest))
+	}
+	for i, column := range r.columns {
+		raw, ok := r.row[column]
+		if !ok {
+			raw = json.RawMessage("null")
+		}
+		var err error
+		if scanner, ok := dest[i].(sql.Scanner); ok {
+			err = scanJSONValue(scanner, raw)
+		} else if t := reflect.TypeOf(dest[i]); isJSONNull(raw) && t != nil && t.Kind() == reflect.Pointer && rejectsNull(t.Elem()) {
+			// json.Unmarshal would leave the destination as is
+			err = fmt.Errorf("converting NULL to %s is unsupported", t.Elem())
+		} else {
+			err = json.Unmarshal(raw, dest[i])
+		}
+		if err != nil {
+			return fmt.Errorf("sqlz: scan column %q: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// isJSONNull reports whether raw is the JSON null.
+func isJSONNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+// scanJSONValue converts raw to a value like a driver would return and passes it to scanner.
+func scanJSONValue(scanner sql.Scanner, raw json.RawMessage) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && (raw[0] == '{' || raw[0] == '[') {
+		return scanner.Scan([]byte(raw))
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			v = i
+		} else if v, err = n.Float64(); err != nil {
+			return err
+		}
+	}
+	return scanner.Scan(v)
+}
@@ -0,0 +1,54 @@
+package sqlz
+
+import "fmt"
+
+// Limit returns Rows that stop after n rows of rows, as if the result set ended there. Columns, Err and Scan are
+// passed through.
+func Limit(rows Rows, n int) Rows {
+	return &limitRows{rows, n}
+}
+
+// limitRows is returned by Limit.
+type limitRows struct {
+	Rows
+	n int // number of rows left
+}
+
+func (r *limitRows) Next() bool {
+	if r.n <= 0 {
+		return false
+	}
+	r.n--
+	return r.Rows.Next()
+}
+
+// prefixRows passes the first k columns of rows through, the other columns are discarded.
+type prefixRows struct {
+	Rows
+	k       int
+	discard []any // placeholders for the discarded columns
+	buf     []any // destinations of all columns, reused across rows
+}
+
+func (r *prefixRows) Columns() ([]string, error) {
+	columns, err := r.Rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if r.k > len(columns) {
+		return nil, fmt.Errorf("sqlz: cannot scan the first %d columns of %d", r.k, len(columns))
+	}
+	if r.discard == nil {
+		r.discard = make([]any, len(columns)-r.k)
+		placeholder := new(any)
+		for i := range r.discard {
+			r.discard[i] = placeholder
+		}
+	}
+	return columns[:r.k], nil
+}
+
+func (r *prefixRows) Scan(dest ...any) error {
+	r.buf = append(append(r.buf[:0], dest...), r.discard...)
+	return r.Rows.Scan(r.buf...)
+}
@@ -0,0 +1,86 @@
+package sqlz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ScanMapInto scans the result set into values of type V and stores them in dst, by the value of their keyColumn.
+// V must be a struct type, or a pointer to one, with a field of type K for keyColumn. Rows are merged into the
+// existing entries of dst: a row whose key is already in dst overwrites it, and so does a later row with the same key.
+// If s is nil, the global Scanner is used. See [Scanner.Scan] for more details.
+//
+// ScanMapInto stops when the context is canceled. The rows scanned so far are kept in dst.
+func ScanMapInto[K comparable, V any](ctx context.Context, s *Scanner, rows Rows, dst map[K]V, keyColumn string) error {
+	if dst == nil {
+		return errors.New("sqlz: ScanMapInto called with nil map")
+	}
+	return scanMap(ctx, s, rows, keyColumn, func(k K, v V) {
+		dst[k] = v
+	})
+}
+
+// ScanOrderedMap is like [ScanMapInto], but it returns a new map, and the keys in the order of the result set.
+// A key that occurs more than once keeps the position of its first row, and the value of its last row.
+func ScanOrderedMap[K comparable, V any](ctx context.Context, s *Scanner, rows Rows, keyColumn string) (keys []K, m map[K]V, err error) {
+	m = make(map[K]V)
+	err = scanMap(ctx, s, rows, keyColumn, func(k K, v V) {
+		if _, ok := m[k]; !ok {
+			keys = append(keys, k)
+		}
+		m[k] = v
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, m, nil
+}
+
+// scanMap scans the result set into values of type V and passes them to store, with the value of their keyColumn.
+func scanMap[K comparable, V any](ctx context.Context, s *Scanner, rows Rows, keyColumn string, store func(K, V)) (err error) {
+	if s == nil {
+		s = Default()
+	}
+	defer s.recoverMisuse(&err)
+	var (
+		opts     = s.options()
+		v        V
+		elem     = reflect.ValueOf(&v).Elem()
+		isPtr    = elem.Kind() == reflect.Pointer
+		elemType = elem.Type()
+	)
+	opts.ctx = ctx
+	if isPtr {
+		elemType = elemType.Elem()
+		elem = reflect.New(elemType).Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		panic("map value type must be a struct")
+	}
+	key, ok := s.structInfo(elemType).fields[keyColumn]
+	if !ok {
+		return fmt.Errorf("sqlz: struct %s has no field for key column %q", elemType, keyColumn)
+	}
+	if t, kt := fieldTypeByIndex(elemType, key.index), reflect.TypeOf((*K)(nil)).Elem(); t != kt {
+		return fmt.Errorf("sqlz: key field of type %s doesn't match map key of type %s", t, kt)
+	}
+	p, err := s.mapFieldDest(elem, rows, &opts)
+	if err != nil {
+		return err
+	}
+	defer p.release()
+	p.ownRawBytes = true
+	return scanRows(ctx, rows, p, elem, func() error {
+		k := fieldByIndex(elem, key.index).Interface().(K)
+		if isPtr {
+			ptr := reflect.New(elemType)
+			ptr.Elem().Set(elem)
+			store(k, ptr.Interface().(V))
+		} else {
+			store(k, elem.Interface().(V))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,129 @@
+package sqlz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ScanNested scans the result set from parentRows into dest, and fills the nested slice field of each parent with
+// the rows of a child query, instead of running a query per parent.
+//
+// dest must be a pointer to a slice of structs with a slice field tagged with `db:",nested=parentkey:childkey"`.
+// After scanning the parents, ScanNested calls child with the values of the parentkey column of all parents, in
+// order. child must return the child rows for these keys, which are scanned into the element type of the nested
+// field and appended to the parents whose parentkey equals their childkey. The key fields of the parent and the
+// child must be of the same type. Pointer keys are compared by the values they point to, and parents with a nil
+// key are left out. If the rows returned by child implement io.Closer, they're closed afterwards.
+//
+// Only one level of nesting is supported: nested fields of the children are not filled.
+func (s *Scanner) ScanNested(ctx context.Context, parentRows Rows, dest any, child func(parentKeys []any) (Rows, error)) (err error) {
+	defer s.recoverMisuse(&err)
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Pointer || destValue.Elem().Kind() != reflect.Slice {
+		panic("dest must be a pointer to a slice")
+	}
+	parents := destValue.Elem()
+	origLen := parents.Len()
+	if err := s.Scan(ctx, parentRows, dest); err != nil {
+		return err
+	}
+	parentType := parents.Type().Elem()
+	if parentType.Kind() == reflect.Pointer {
+		parentType = parentType.Elem()
+	}
+	info := s.structInfo(parentType)
+	if info.nested == nil {
+		return fmt.Errorf("sqlz: struct %s has no nested field", parentType)
+	}
+	parentKey, ok := info.fields[info.nested.parentKey]
+	if !ok {
+		return fmt.Errorf("sqlz: struct %s has no field for parent key %q", parentType, info.nested.parentKey)
+	}
+
+	nestedType := fieldTypeByIndex(parentType, info.nested.index)
+	childType := nestedType.Elem()
+	if childType.Kind() == reflect.Pointer {
+		childType = childType.Elem()
+	}
+	childKey, ok := s.structInfo(childType).fields[info.nested.childKey]
+	if !ok {
+		return fmt.Errorf("sqlz: struct %s has no field for child key %q", childType, info.nested.childKey)
+	}
+	keyType := fieldTypeByIndex(parentType, parentKey.index)
+	if t := fieldTypeByIndex(childType, childKey.index); t != keyType {
+		return fmt.Errorf("sqlz: child key of type %s doesn't match parent key of type %s", t, keyType)
+	}
+
+	// Group the parents by key.
+	var (
+		keys  []any
+		byKey = make(map[any][]reflect.Value)
+	)
+	for i := origLen; i < parents.Len(); i++ {
+		parent := reflect.Indirect(parents.Index(i))
+		key, arg, err := nestedKey(fieldByIndex(parent, parentKey.index))
+		if err != nil {
+			return err
+		} else if key == nil {
+			continue // a parent without key has no children
+		}
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, arg)
+		}
+		byKey[key] = append(byKey[key], fieldByIndex(parent, info.nested.index))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	rows, err := child(keys)
+	if err != nil {
+		return err
+	}
+	if c, ok := rows.(io.Closer); ok {
+		defer c.Close()
+	}
+	children := reflect.New(nestedType)
+	if err = s.Scan(ctx, rows, children.Interface()); err != nil {
+		return err
+	}
+	children = children.Elem()
+
+	// Distribute the children over their parents.
+	for i := 0; i < children.Len(); i++ {
+		elem := children.Index(i)
+		key, _, err := nestedKey(fieldByIndex(reflect.Indirect(elem), childKey.index))
+		if err != nil {
+			return err
+		}
+		for _, field := range byKey[key] {
+			field.Set(reflect.Append(field, elem))
+		}
+	}
+	return nil
+}
+
+// nestedKey returns the map key that groups the parents or children with key v, and the value passed to the
+// child query for it. Pointers are dereferenced and byte slices are turned into strings, so equal keys match.
+// The key is nil if v is nil.
+func nestedKey(v reflect.Value) (key, arg any, err error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return nil, nil, fmt.Errorf("sqlz: key field of type %s is unexported", v.Type())
+	}
+	arg = v.Interface()
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return string(v.Bytes()), arg, nil
+	}
+	if !v.Comparable() {
+		return nil, nil, fmt.Errorf("sqlz: key of type %s is not comparable", v.Type())
+	}
+	return arg, arg, nil
+}
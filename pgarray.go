@@ -0,0 +1,153 @@
+package sqlz
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// isPgArrayElem reports whether t can be the element type of a slice that's scanned from a Postgres array.
+func isPgArrayElem(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertPgArray parses the text form of a one-dimensional Postgres array, like {a,b,"c,d"}, into a slice.
+// NULL elements are stored as nil for pointer elements, and as the zero value otherwise. A NULL array is stored
+// as a nil slice.
+func convertPgArray(src any, dst reflect.Value) error {
+	var s string
+	switch x := src.(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case nil:
+		dst.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("sqlz: unsupported Scan, converting %T to %s", src, dst.Type())
+	}
+	elems, err := parsePgArray(s)
+	if err != nil {
+		return fmt.Errorf("sqlz: converting %q to an array: %w", s, err)
+	}
+	slice := reflect.MakeSlice(dst.Type(), len(elems), len(elems))
+	for i, elem := range elems {
+		if elem == nil {
+			continue
+		}
+		v := slice.Index(i)
+		if v.Kind() == reflect.Pointer {
+			v.Set(reflect.New(v.Type().Elem()))
+			v = v.Elem()
+		}
+		if err = setPgArrayElem(v, *elem); err != nil {
+			return fmt.Errorf("sqlz: converting %q to an array: element %d: %w", s, i, err)
+		}
+	}
+	dst.Set(slice)
+	return nil
+}
+
+// setPgArrayElem parses s into v.
+func setPgArrayElem(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
+
+// parsePgArray parses the text form of a one-dimensional Postgres array. NULL elements are returned as nil.
+func parsePgArray(s string) ([]*string, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, errors.New("missing braces")
+	}
+	s = s[1 : len(s)-1]
+	if s == "" {
+		return []*string{}, nil
+	}
+	var elems []*string
+	for i := 0; ; {
+		var elem *string
+		switch {
+		case i < len(s) && s[i] == '"':
+			var b strings.Builder
+			for i++; ; i++ {
+				if i >= len(s) {
+					return nil, errors.New("unterminated quoted element")
+				}
+				if s[i] == '\\' {
+					if i++; i >= len(s) {
+						return nil, errors.New("unterminated quoted element")
+					}
+				} else if s[i] == '"' {
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+			}
+			v := b.String()
+			elem = &v
+		case i < len(s) && s[i] == '{':
+			return nil, errors.New("multidimensional arrays are unsupported")
+		default:
+			end := strings.IndexByte(s[i:], ',')
+			if end < 0 {
+				end = len(s) - i
+			}
+			v := strings.TrimSpace(s[i : i+end])
+			i += end
+			if v == "" {
+				return nil, errors.New("empty element")
+			}
+			if !strings.EqualFold(v, "NULL") {
+				elem = &v
+			}
+		}
+		elems = append(elems, elem)
+		if i == len(s) {
+			return elems, nil
+		}
+		if s[i] != ',' {
+			return nil, fmt.Errorf("expected , at offset %d", i+1)
+		}
+		i++
+	}
+}
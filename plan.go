@@ -0,0 +1,679 @@
+package sqlz
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A plan holds the scan destinations for the rows of a result set.
+type plan struct {
+	values []any
+	convs  []convertValue // buffer of converting destinations in values, never grown
+	fields []*fieldInfo   // buffer of the fields that the columns map to
+	pooled bool           // whether p is returned to the pool by release
+
+	extra        reflect.Value // map field that receives unmapped columns, invalid if there is none
+	extraColumns []string
+	extraValues  []*any
+	extraJSON    bool // whether extra receives the columns as a JSON object
+
+	strings []*string         // string fields to intern
+	interns map[string]string // intern table of strings
+
+	rownum reflect.Value // field that receives the row number, invalid if there is none
+	row    int64         // number of the last scanned row
+
+	setters []setterCall
+	zeroes  []nullZero // fields that are set to their zero value for NULL
+
+	copies []fieldCopy // fields that receive a copy of another field, or of a value resolved from the context
+
+	lazy []*lazyStruct // pointer to struct fields that are allocated when one of their columns isn't NULL
+
+	times    []reflect.Value // time.Time and *time.Time fields to convert to location
+	location *time.Location
+
+	rawBytes    []*sql.RawBytes // RawBytes fields, these are only valid until the next row
+	ownRawBytes bool            // whether to copy RawBytes fields after each scan
+
+	columns   []string                       // columns of the result set, only set when profiling
+	durations map[string]time.Duration       // scan durations by column, nil if not profiling
+	report    func(map[string]time.Duration) // receives durations when p is released
+}
+
+// setterCall calls a setter method with the value scanned into arg.
+type setterCall struct {
+	method reflect.Value
+	arg    reflect.Value
+}
+
+// fieldCopy copies the value of the field src to dst, see cloneValue.
+type fieldCopy struct {
+	src reflect.Value
+	dst reflect.Value
+}
+
+// lazyStruct sets a pointer to struct field to a new struct holding the values scanned into temps, or to nil if
+// all of them are NULL (nil).
+type lazyStruct struct {
+	ptr    reflect.Value
+	parent []uint16
+	fields []lazyField
+}
+
+// lazyField is a field of a lazyStruct, with the index relative to the struct.
+type lazyField struct {
+	index  []uint16
+	temp   reflect.Value // pointer to the field type
+	intern bool          // whether the string value is interned
+	clone  bool          // whether the value is cloned, because it's a copy of another field
+}
+
+// set sets the pointer of l, and resets the temps. Strings are interned in interns, if the field asks for it.
+func (l *lazyStruct) set(interns map[string]string) {
+	var v reflect.Value
+	for _, f := range l.fields {
+		if f.temp.IsNil() {
+			continue
+		}
+		if !v.IsValid() {
+			v = reflect.New(l.ptr.Type().Elem())
+		}
+		field := fieldByIndex(v.Elem(), f.index)
+		if f.clone {
+			field.Set(cloneValue(f.temp.Elem()))
+		} else {
+			field.Set(f.temp.Elem())
+		}
+		if f.intern {
+			if s, ok := interns[field.String()]; ok {
+				field.SetString(s)
+			} else {
+				interns[field.String()] = field.String()
+			}
+		}
+	}
+	for _, f := range l.fields {
+		f.temp.SetZero()
+	}
+	if v.IsValid() {
+		l.ptr.Set(v)
+	} else {
+		l.ptr.SetZero()
+	}
+}
+
+// lazyTarget returns the scan destination for the field x of a pointer to struct, and adds it to the lazyStruct.
+func (p *plan) lazyTarget(dest reflect.Value, x *fieldInfo, opts *scanOptions) any {
+	var l *lazyStruct
+	for _, y := range p.lazy {
+		if slices.Equal(y.parent, x.parent) {
+			l = y
+			break
+		}
+	}
+	if l == nil {
+		l = &lazyStruct{ptr: fieldByIndex(dest, x.parent), parent: x.parent}
+		p.lazy = append(p.lazy, l)
+	}
+	t := fieldTypeByIndex(dest.Type(), x.index)
+	temp := reflect.New(reflect.PointerTo(t)).Elem()
+	intern := opts.internStrings && t == stringType
+	if intern && p.interns == nil {
+		p.interns = make(map[string]string)
+	}
+	l.fields = append(l.fields, lazyField{x.index[len(x.parent):], temp, intern, false})
+	for _, index := range x.copies {
+		l.fields = append(l.fields, lazyField{index[len(x.parent):], temp, intern, true})
+	}
+	y := *x // the temp is a pointer to the field, NULL is stored as nil
+	if y.conv != nil {
+		y.conv = nullable(y.conv)
+	}
+	return p.target(&y, temp, opts)
+}
+
+// nullZero stores the value scanned into ptr in field, or the zero value if it's NULL (nil).
+type nullZero struct {
+	ptr   reflect.Value
+	field reflect.Value
+}
+
+// columnFields returns the field of the struct type t, described by info, that each column maps to. It's nil
+// for columns that are discarded, or received by the extra field. It returns an error if a column can't be mapped.
+// The fields are stored in buf if it holds one per column.
+func (s *Scanner) columnFields(t reflect.Type, info *structInfo, columns []string, opts *scanOptions, buf []*fieldInfo) ([]*fieldInfo, error) {
+	if len(info.fields) == 0 && len(info.positions) == 0 && info.extra == nil {
+		return nil, fmt.Errorf("sqlz: struct %s has no scannable fields", t)
+	}
+	if opts.positional && len(columns) != len(info.ordered) {
+		return nil, fmt.Errorf("sqlz: positional scan into %s requires %d columns, not %d", t, len(info.ordered), len(columns))
+	}
+	for n, x := range info.positions {
+		if n >= len(columns) {
+			return nil, fmt.Errorf("sqlz: field %s of %s is out of range of %d columns", x.name, t, len(columns))
+		}
+	}
+	var fallback map[int]*fieldInfo
+	if opts.fallbackPositional && !opts.positional && opts.wanted == nil && len(columns) == len(info.ordered) {
+		fallback = fallbackFields(info, columns, opts)
+	}
+	var mapped map[*fieldInfo]string // columns by field, to detect conflicts in strict mode
+	if opts.strict {
+		mapped = make(map[*fieldInfo]string, len(columns))
+	}
+	fields := buf
+	if len(fields) != len(columns) {
+		fields = make([]*fieldInfo, len(columns))
+	}
+	for i, column := range columns {
+		if !opts.wants(column) || opts.denied(column) {
+			continue
+		}
+		x, ok := info.positions[i] // fields tagged with a position take precedence
+		if !ok && opts.positional {
+			x, ok = info.ordered[i], true
+		} else if !ok {
+			x, ok = info.fields[opts.fieldName(column)]
+			if !ok && fallback != nil {
+				x, ok = fallback[i], true
+			}
+		}
+		if !ok {
+			if info.extra != nil && opts.wanted == nil {
+				continue
+			} else if !opts.ignoreUnknownColumns || opts.wanted != nil {
+				return nil, fmt.Errorf("sqlz: missing field mapping for column %q", column)
+			}
+			if opts.logger != nil && s.tc.warnOnce(t, column) {
+				opts.logger.Warn("sqlz: ignoring unknown column", "column", column, "type", t.String())
+			}
+			continue
+		}
+		if mapped != nil {
+			if prev, dup := mapped[x]; dup {
+				return nil, fmt.Errorf("sqlz: columns %q and %q map to the same field %s", prev, column, x.name)
+			}
+			mapped[x] = column
+		}
+		fields[i] = x
+	}
+	for _, name := range opts.wanted {
+		if !slices.ContainsFunc(columns, func(column string) bool { return opts.fieldName(column) == name }) {
+			return nil, fmt.Errorf("sqlz: wanted column %q is not in the result set", name)
+		}
+	}
+	return fields, nil
+}
+
+func (s *Scanner) mapFieldDest(dest reflect.Value, rows Rows, opts *scanOptions) (*plan, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	info := s.structInfo(dest.Type())
+	p := newPlan(len(columns))
+	fields, err := s.columnFields(dest.Type(), info, columns, opts, p.fields)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	p.location = opts.location
+	if info.extra != nil {
+		p.extra = fieldByIndex(dest, info.extra)
+		p.extraJSON = info.extraJSON
+	}
+	if info.rownum != nil {
+		p.rownum = fieldByIndex(dest, info.rownum)
+	}
+	var placeholder *any            // shared by all discarded columns, allocated when needed
+	var fromCtx map[*fieldInfo]bool // ctx fields that have no column yet
+	if opts.resolveField != nil && len(info.fromCtx) > 0 {
+		fromCtx = make(map[*fieldInfo]bool, len(info.fromCtx))
+		for _, x := range info.fromCtx {
+			fromCtx[x] = true
+		}
+	}
+	for i, column := range columns {
+		x := fields[i]
+		if x != nil {
+			delete(fromCtx, x)
+		}
+		if x != nil && x.parent != nil {
+			p.values[i] = p.lazyTarget(dest, x, opts)
+		} else if x != nil && x.setter != nil {
+			recv := fieldByIndex(dest, x.index[:len(x.index)-1]).Addr()
+			arg := reflect.New(x.setter.Type.In(1)).Elem()
+			p.setters = append(p.setters, setterCall{recv.Method(x.setter.Index), arg})
+			p.values[i] = p.target(x, arg, opts)
+		} else if x != nil {
+			field := fieldByIndex(dest, x.index)
+			for _, index := range x.copies {
+				p.copies = append(p.copies, fieldCopy{field, fieldByIndex(dest, index)})
+			}
+			v := p.target(x, field, opts)
+			if opts.internStrings && field.Type() == stringType {
+				// the field itself is interned, so its value is interned after it's sanitized or converted from NULL
+				p.strings = append(p.strings, field.Addr().Interface().(*string))
+			}
+			if rb, ok := v.(*sql.RawBytes); ok {
+				p.rawBytes = append(p.rawBytes, rb)
+			}
+			p.values[i] = v
+		} else if p.extra.IsValid() && opts.wanted == nil && !opts.denied(column) {
+			v := new(any)
+			p.values[i] = v
+			p.extraColumns = append(p.extraColumns, column)
+			p.extraValues = append(p.extraValues, v)
+		} else {
+			if placeholder == nil {
+				placeholder = new(any)
+			}
+			p.values[i] = placeholder
+		}
+	}
+	for _, x := range info.fromCtx {
+		if !fromCtx[x] {
+			continue
+		}
+		ctx := opts.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		v, ok := opts.resolveField(ctx, x.name)
+		if !ok {
+			continue
+		}
+		field := fieldByIndex(dest, x.index)
+		rv := reflect.ValueOf(v)
+		switch {
+		case rv.IsValid() && rv.Type().AssignableTo(field.Type()):
+			rv = rv.Convert(field.Type())
+		case rv.IsValid() && scanTypeClass(rv.Type()) == classNumber && scanTypeClass(field.Type()) == classNumber:
+			// only convert numbers that survive the round trip, so they aren't truncated or wrapped
+			c := rv.Convert(field.Type())
+			if !c.Convert(rv.Type()).Equal(rv) {
+				return nil, fmt.Errorf("sqlz: value %v from the context doesn't fit in field %s of type %s", v, x.name, field.Type())
+			}
+			rv = c
+		default:
+			return nil, fmt.Errorf("sqlz: value %v from the context can't be stored in field %s of type %s", v, x.name, field.Type())
+		}
+		p.copies = append(p.copies, fieldCopy{rv, field})
+	}
+	if p.strings != nil && p.interns == nil {
+		p.interns = make(map[string]string)
+	}
+	if opts.profile != nil {
+		p.columns = columns
+		p.durations = make(map[string]time.Duration, len(columns))
+		p.report = opts.profile
+	}
+	return p, nil
+}
+
+// target returns the scan destination for v, which holds the value of the field x.
+func (p *plan) target(x *fieldInfo, v reflect.Value, opts *scanOptions) any {
+	base, depth := v.Type(), 0 // type that v points to, through depth pointers
+	for base.Kind() == reflect.Pointer {
+		base, depth = base.Elem(), depth+1
+	}
+	if opts.location != nil && base == timeType {
+		p.times = append(p.times, v)
+	}
+	conv := x.conv
+	if conv == nil && opts.sanitizeString != nil && base == stringType {
+		conv = convertString(opts.sanitizeString)
+		for i := 0; i < depth; i++ {
+			conv = nullable(conv)
+		}
+	}
+	if !opts.nullAsZero || !rejectsNull(v.Type()) {
+		if conv != nil {
+			return p.convert(v, conv)
+		}
+		return v.Addr().Interface()
+	}
+	if conv != nil {
+		return p.convert(v, zeroOnNull(conv))
+	}
+	// database/sql stores NULL as nil in pointers, and converts other values just like it does for v.
+	ptr := reflect.New(reflect.PointerTo(v.Type())).Elem()
+	p.zeroes = append(p.zeroes, nullZero{ptr, v})
+	return ptr.Addr().Interface()
+}
+
+// rejectsNull reports whether scanning NULL into a value of type t fails.
+func rejectsNull(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return false
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// maxPooledValues is the largest number of columns for which plans are pooled.
+const maxPooledValues = 64
+
+// planPool pools plans with their buffers. Plans are only used during a scan, so they can be reused by the next
+// scan, which saves their allocations when scanning single rows.
+var planPool sync.Pool
+
+// newPlan returns a plan with n values, taken from the pool when possible.
+func newPlan(n int) *plan {
+	if n > maxPooledValues {
+		return &plan{values: make([]any, n), convs: make([]convertValue, 0, n), fields: make([]*fieldInfo, n)}
+	}
+	p, _ := planPool.Get().(*plan)
+	if p == nil {
+		p = &plan{
+			values: make([]any, 0, maxPooledValues),
+			convs:  make([]convertValue, 0, maxPooledValues),
+			fields: make([]*fieldInfo, 0, maxPooledValues),
+		}
+	}
+	p.values, p.fields, p.pooled = p.values[:n], p.fields[:n], true
+	return p
+}
+
+// convert returns a destination that converts the scanned value through conv and stores it in v.
+func (p *plan) convert(v reflect.Value, conv converter) *convertValue {
+	if len(p.convs) == cap(p.convs) {
+		return &convertValue{v, conv} // appending would move the destinations that are already in values
+	}
+	p.convs = append(p.convs, convertValue{v, conv})
+	return &p.convs[len(p.convs)-1]
+}
+
+// release reports the scan durations of p, if profiling, and returns its values to the pool.
+// p must not be used afterwards.
+func (p *plan) release() {
+	if p.report != nil {
+		p.report(p.durations)
+	}
+	if !p.pooled {
+		return
+	}
+	// don't keep the destinations alive
+	for i := range p.values {
+		p.values[i] = nil
+	}
+	for i := range p.convs {
+		p.convs[i] = convertValue{}
+	}
+	for i := range p.fields {
+		p.fields[i] = nil
+	}
+	*p = plan{values: p.values[:0], convs: p.convs[:0], fields: p.fields[:0]}
+	planPool.Put(p)
+}
+
+// fallbackFields maps the columns that don't match a field by name to the fields that don't match a column,
+// by position. It returns the fields by column index. The number of columns must equal the number of fields.
+func fallbackFields(info *structInfo, columns []string, opts *scanOptions) map[int]*fieldInfo {
+	matched := make(map[*fieldInfo]bool, len(columns))
+	for _, column := range columns {
+		if x, ok := info.fields[opts.fieldName(column)]; ok {
+			matched[x] = true
+		}
+	}
+	var (
+		fallback = make(map[int]*fieldInfo)
+		next     = 0
+	)
+	for i, column := range columns {
+		if _, ok := info.fields[opts.fieldName(column)]; ok {
+			continue
+		}
+		for matched[info.ordered[next]] {
+			next++
+		}
+		fallback[i] = info.ordered[next]
+		next++
+	}
+	return fallback
+}
+
+// mapDest returns a plan for dest, which is either a scalar or a struct.
+func (s *Scanner) mapDest(dest reflect.Value, rows Rows, opts *scanOptions) (*plan, error) {
+	if s.isScalar(dest.Type()) {
+		return s.mapScalarDest(dest, rows)
+	}
+	return s.mapFieldDest(dest, rows, opts)
+}
+
+// mapScalarDest returns a plan that scans the only column of rows into dest, using the converter registered for
+// its type, if any.
+func (s *Scanner) mapScalarDest(dest reflect.Value, rows Rows) (*plan, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != 1 {
+		return nil, fmt.Errorf("sqlz: scanning into %s requires exactly one column, not %d", dest.Type(), len(columns))
+	}
+	if conv := s.kindConverter(dest.Type()); conv != nil {
+		return &plan{values: []any{&convertValue{dest, conv}}}, nil
+	}
+	p := &plan{
+		values: []any{scanTarget(dest)},
+	}
+	if rb, ok := p.values[0].(*sql.RawBytes); ok {
+		p.rawBytes = []*sql.RawBytes{rb}
+	}
+	return p, nil
+}
+
+// isScalar reports whether t is scanned as a single column. That's the case for all types except structs
+// (and pointers to structs), unless the struct is a time.Time, implements [sql.Scanner] or has a converter.
+func isScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Struct || t == timeType || reflect.PointerTo(t).Implements(scannerType) || converterFor(t) != nil
+}
+
+// isScalar is like the function isScalar, but it also treats the types of kinds registered on s, or globally, as
+// scalars, and pointers to them.
+func (s *Scanner) isScalar(t reflect.Type) bool {
+	return isScalar(t) || s.kindConverter(t) != nil
+}
+
+// kindConverter returns the converter of the kind registered for t, or for the type t points to, or nil if there's
+// none.
+func (s *Scanner) kindConverter(t reflect.Type) converter {
+	if conv := s.tc.kind(t); conv != nil {
+		return conv
+	}
+	if t.Kind() == reflect.Pointer {
+		if conv := s.tc.kind(t.Elem()); conv != nil {
+			return nullable(conv)
+		}
+	}
+	return nil
+}
+
+// wants reports whether column is scanned by ScanColumns. Like in denied, the wanted columns are matched after
+// NormalizeColumn and StripColumnPrefix.
+func (o *scanOptions) wants(column string) bool {
+	return o.wanted == nil || slices.Contains(o.wanted, o.fieldName(column))
+}
+
+// denied reports whether column must be discarded because of DenyColumns or AllowColumns.
+func (o *scanOptions) denied(column string) bool {
+	if o.denyColumns == nil && o.allowColumns == nil {
+		return false
+	}
+	name := o.fieldName(column)
+	return slices.Contains(o.denyColumns, name) || o.allowColumns != nil && !slices.Contains(o.allowColumns, name)
+}
+
+// fieldName returns the name of the field that column maps to.
+func (o *scanOptions) fieldName(column string) string {
+	if o.normalizeColumn != nil {
+		column = o.normalizeColumn(column)
+	}
+	if o.stripColumnPrefix != "" {
+		if _, after, ok := strings.Cut(column, o.stripColumnPrefix); ok {
+			column = after
+		}
+	}
+	return column
+}
+
+// scan scans the current row of rows into the destinations of p.
+func (p *plan) scan(rows Rows) error {
+	if p.durations != nil {
+		if err := p.profileScan(rows); err != nil {
+			return err
+		}
+	} else if err := rows.Scan(p.values...); err != nil {
+		return err
+	}
+	if len(p.extraColumns) > 0 {
+		m := make(map[string]any, len(p.extraColumns))
+		for i, column := range p.extraColumns {
+			m[column] = *p.extraValues[i]
+		}
+		if p.extraJSON {
+			if err := setRest(p.extra, m); err != nil {
+				return err
+			}
+		} else {
+			p.extra.Set(reflect.ValueOf(m))
+		}
+	}
+	if p.rownum.IsValid() {
+		p.row++
+		p.rownum.SetInt(p.row)
+	}
+	for _, nz := range p.zeroes {
+		if nz.ptr.IsNil() {
+			nz.field.SetZero()
+		} else {
+			nz.field.Set(nz.ptr.Elem())
+			nz.ptr.SetZero()
+		}
+	}
+	for _, v := range p.times {
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Pointer && !v.Interface().(time.Time).IsZero() {
+			v.Set(reflect.ValueOf(v.Interface().(time.Time).In(p.location)))
+		}
+	}
+	for _, sp := range p.strings {
+		if v, ok := p.interns[*sp]; ok {
+			*sp = v
+		} else {
+			p.interns[*sp] = *sp
+		}
+	}
+	if p.ownRawBytes {
+		for _, rb := range p.rawBytes {
+			*rb = bytes.Clone(*rb)
+		}
+	}
+	for _, fc := range p.copies {
+		fc.dst.Set(cloneValue(fc.src))
+	}
+	for _, l := range p.lazy {
+		l.set(p.interns)
+	}
+	for _, sc := range p.setters {
+		out := sc.method.Call([]reflect.Value{sc.arg})
+		if len(out) == 1 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+		sc.arg.SetZero()
+	}
+	return nil
+}
+
+// cloneValue returns a copy of v that doesn't share memory with it: slices, maps and pointers are copied shallowly.
+// Other values are returned as is.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if !v.IsNil() {
+			c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			reflect.Copy(c, v)
+			return c
+		}
+	case reflect.Map:
+		if !v.IsNil() {
+			c := reflect.MakeMapWithSize(v.Type(), v.Len())
+			for iter := v.MapRange(); iter.Next(); {
+				c.SetMapIndex(iter.Key(), iter.Value())
+			}
+			return c
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			c := reflect.New(v.Type().Elem())
+			c.Elem().Set(v.Elem())
+			return c
+		}
+	}
+	return v
+}
+
+// setRest stores the columns in m as a JSON object in the rest field, which is a json.RawMessage or map[string]any.
+// Byte slices are stored as strings, since drivers commonly return text that way.
+func setRest(field reflect.Value, m map[string]any) error {
+	for column, v := range m {
+		if b, ok := v.([]byte); ok {
+			m[column] = string(b)
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("sqlz: encoding rest columns as JSON: %w", err)
+	}
+	if field.Type() == rawMessageType {
+		field.SetBytes(data)
+		return nil
+	}
+	var rest map[string]any
+	if err = json.Unmarshal(data, &rest); err != nil {
+		return fmt.Errorf("sqlz: decoding rest columns: %w", err)
+	}
+	field.Set(reflect.ValueOf(rest))
+	return nil
+}
+
+// discard is a scan destination that ignores the value.
+type discard struct{}
+
+func (discard) Scan(any) error {
+	return nil
+}
+
+// profileScan scans the current row column by column, and adds the time each column takes to p.durations.
+func (p *plan) profileScan(rows Rows) error {
+	values := make([]any, len(p.values))
+	for i := range values {
+		values[i] = discard{}
+	}
+	for i, v := range p.values {
+		values[i] = v
+		start := time.Now()
+		if err := rows.Scan(values...); err != nil {
+			return err
+		}
+		p.durations[p.columns[i]] += time.Since(start)
+		values[i] = discard{}
+	}
+	return nil
+}
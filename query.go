@@ -0,0 +1,82 @@
+package sqlz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// A Querier executes queries. It's implemented by [sql.DB], [sql.Tx] and [sql.Conn].
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// GetPtr executes the query and scans the first row into a new T, which must be a struct type.
+// It uses the global Scanner. See [Scanner.Scan] for more details.
+//
+// If the query returns no rows, GetPtr returns nil, nil: a nil pointer without an error means "not found".
+func GetPtr[T any](ctx context.Context, db Querier, query string, args ...any) (*T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dest := new(T)
+	if err = Scan(ctx, rows, dest); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return dest, rows.Close()
+}
+
+// MustGet is like [GetPtr], but it returns the row by value and panics if the query fails or returns no rows.
+func MustGet[T any](ctx context.Context, db Querier, query string, args ...any) T {
+	dest, err := GetPtr[T](ctx, db, query, args...)
+	if err == nil && dest == nil {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		panic(fmt.Errorf("sqlz: MustGet: %w", err))
+	}
+	return *dest
+}
+
+// MustSelect executes the query and scans all rows into a slice of T, using the global Scanner.
+// It panics if the query fails.
+func MustSelect[T any](ctx context.Context, db Querier, query string, args ...any) []T {
+	dest, err := selectAll[T](ctx, db, query, args...)
+	if err != nil {
+		panic(fmt.Errorf("sqlz: MustSelect: %w", err))
+	}
+	return dest
+}
+
+// Pluck executes the query and scans the only column of all rows into a slice of T, using the global Scanner.
+// T must be a scalar type, like int or string, it can be a pointer to hold NULL. Pluck returns an error if the
+// result set doesn't have exactly one column, or an error that wraps [ErrMisuse] if T isn't a scalar type.
+func Pluck[T any](ctx context.Context, db Querier, query string, args ...any) ([]T, error) {
+	if !Default().isScalar(reflect.TypeOf((*T)(nil)).Elem()) {
+		return nil, misuseError("Pluck requires a scalar type")
+	}
+	return selectAll[T](ctx, db, query, args...)
+}
+
+// selectAll executes the query and scans all rows into a slice of T, using the global Scanner.
+func selectAll[T any](ctx context.Context, db Querier, query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dest []T
+	if err = Scan(ctx, rows, &dest); err != nil {
+		return nil, err
+	}
+	return dest, rows.Close()
+}
@@ -0,0 +1,75 @@
+package sqlz
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+)
+
+// A RetryPolicy controls which errors [Scanner.ScanRetry] retries and how often.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+
+	// Retryable reports whether err is transient, like a deadlock or serialization failure.
+	// Errors of rows scanned into a slice are wrapped in a [RowError], use [errors.Is] or [errors.As] to inspect them.
+	// Errors are never retried if it's nil.
+	Retryable func(err error) bool
+
+	// Backoff returns how long to wait before the given retry, starting at 1.
+	// There's no delay between attempts if it's nil.
+	Backoff func(retry int) time.Duration
+}
+
+// ScanRetry is like Scan, but it retries when the query or scan fails with an error that policy considers retryable.
+// A partially consumed result set can't be rewound, so every attempt re-executes the query by calling query.
+// If the rows returned by query implement io.Closer, they're closed after each attempt.
+//
+// If dest is a pointer to a slice, the rows appended by a failed attempt are removed.
+// Rows that have been sent to a channel by a failed attempt can't be taken back, so ScanRetry is not suited for channels.
+func (s *Scanner) ScanRetry(ctx context.Context, query func() (Rows, error), dest any, policy RetryPolicy) error {
+	var (
+		slice   reflect.Value
+		origLen int
+	)
+	if v := reflect.ValueOf(dest); v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Slice {
+		slice = v.Elem()
+		origLen = slice.Len()
+	}
+	for retry := 1; ; retry++ {
+		err := s.scanQuery(ctx, query, dest)
+		if err == nil {
+			return nil
+		}
+		if slice.IsValid() {
+			for i := origLen; i < slice.Len(); i++ {
+				slice.Index(i).SetZero()
+			}
+			slice.SetLen(origLen)
+		}
+		if retry >= policy.MaxAttempts || policy.Retryable == nil || !policy.Retryable(err) {
+			return err
+		}
+		if policy.Backoff != nil {
+			t := time.NewTimer(policy.Backoff(retry))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+}
+
+func (s *Scanner) scanQuery(ctx context.Context, query func() (Rows, error), dest any) error {
+	rows, err := query()
+	if err != nil {
+		return err
+	}
+	if c, ok := rows.(io.Closer); ok {
+		defer c.Close()
+	}
+	return s.Scan(ctx, rows, dest)
+}
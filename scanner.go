@@ -3,81 +3,417 @@ package sqlz
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
 )
 
 // A Scanner is for scanning result sets from rows into a destination structure.
 // It maintains an internal type cache for mapping struct fields to database columns.
 // It's safe for concurrent use by multiple goroutines. The zero value is ready to use.
+//
+// The fields of a Scanner configure how it maps struct fields to columns. They're read on every call, so they can be
+// changed between calls, but not while the Scanner is in use by other goroutines.
 type Scanner struct {
 	tc cache
 
 	// IgnoreUnknownColumns controls whether Scan will return an error if a column in the result set has no corresponding struct field.
 	// Default is false (return an error).
 	IgnoreUnknownColumns bool
+
+	// PartialOnCancel controls whether scanning into a slice keeps the rows scanned so far when the context is canceled.
+	// If true, the slice holds these rows even though Scan returns the context's error.
+	// Default is false (the slice is restored to its original length).
+	PartialOnCancel bool
+
+	// InternStrings controls whether equal values scanned into string fields share their memory. The intern table
+	// is scoped to a single Scan call. Default is false.
+	InternStrings bool
+
+	// PrefixSeparator is put between the `db` tag of an embedded struct and the names of its fields.
+	// For example, with a separator of "_", the field City of an embedded struct tagged with `db:"address"` maps
+	// to the column address_city. Default is "" (the tag and name are concatenated), except for the structs of
+	// tagged pointer fields, which default to "_".
+	PrefixSeparator string
+
+	// StripColumnPrefix is used to remove qualifiers from column names before they're matched to struct fields.
+	// Everything up to and including its first occurrence in a column name is removed. For example, with ".",
+	// the column users.id maps to id. Default is "" (column names are used as is).
+	StripColumnPrefix string
+
+	// CloseChanOnDone controls whether Scan closes the destination channel when it returns. If true, the channel is
+	// closed after the last row has been sent, no matter why Scan returns: the result set is exhausted, an error
+	// occurred, or the context was canceled. Default is false (the caller closes the channel).
+	CloseChanOnDone bool
+
+	// ZeroBeforeScan controls whether a destination struct is set to its zero value before a row is scanned into it.
+	// Slices and channels are always filled with zeroed structs. Default is false.
+	ZeroBeforeScan bool
+
+	// Positional controls whether columns are mapped to struct fields by their position instead of their name.
+	// The columns map to the scannable fields in declaration order, including those of embedded structs. The number
+	// of columns must match the number of fields. Default is false (columns are mapped by name).
+	Positional bool
+
+	// TagPriority lists the keys of the struct tags that are consulted for the column name and options of a field.
+	// The first non-empty tag is used, so with []string{"db", "json"}, fields without a `db` tag use their `json` tag.
+	// A name of "-" in the used tag skips the field. Fields without any of the tags use their lowercased name.
+	// Default is nil (only the `db` tag is consulted).
+	TagPriority []string
+
+	// Dialect, if set, selects a dialect-specific tag that's consulted before the others. With a dialect of "sqlite",
+	// a field tagged with `db:"created_at" db_sqlite:"createdAt"` maps to the column createdAt.
+	// Default is "" (no dialect-specific tags).
+	Dialect string
+
+	// NullAsZero controls whether NULL is scanned into fields that can't hold it as their zero value, instead of
+	// returning an error. For example, NULL is scanned into a string field as "". Pointers, []byte and types that
+	// implement [sql.Scanner] handle NULL themselves and aren't affected. Default is false.
+	NullAsZero bool
+
+	// MaxDepth is the maximum depth of structs nested in a destination struct, like embedded structs. Mapping a struct
+	// that exceeds it panics, which guards against endlessly recursive types. The structs of tagged pointer fields
+	// aren't traversed any further, so a self-referencing pointer doesn't recurse. Default is 0 (a depth of 10).
+	MaxDepth int
+
+	// FallbackPositional controls whether columns that don't match a field by name are mapped by position, if the
+	// number of columns equals the number of scannable fields. These columns are mapped in order to the fields that
+	// aren't matched by name, in declaration order. A column that's missing from a query, or a misspelled name,
+	// silently maps to the wrong field. Default is false.
+	FallbackPositional bool
+
+	// ColumnProfile, if set, is called at the end of every scan into a struct, or a slice or channel of structs, with
+	// the time spent scanning each column, summed over all rows. Every row is scanned once per column, instead of
+	// once, which makes scanning much slower. Default is nil (no profiling).
+	ColumnProfile func(durations map[string]time.Duration)
+
+	// NormalizeColumn, if set, is applied to column names before they're matched to struct fields, and before
+	// StripColumnPrefix, like [TrimQuotes]. Default is nil (column names are used as is).
+	NormalizeColumn func(column string) string
+
+	// NoPanic controls whether misuse, like an unsupported destination or an invalid struct tag, is reported by
+	// returning an error that wraps [ErrMisuse], instead of panicking. Default is false (misuse panics).
+	NoPanic bool
+
+	// Location, if set, is the location that time.Time and *time.Time fields are converted to after scanning, with
+	// [time.Time.In]. Zero times are left as is. Default is nil (times are left in the location returned by the driver).
+	Location *time.Location
+
+	// StringSanitize, if set, converts byte slices returned by the driver for string and *string fields, instead of
+	// copying them as is. Default is nil (no sanitizing).
+	StringSanitize func(b []byte) string
+
+	// Strict controls whether Scan returns an error if more than one column maps to the same struct field, like
+	// when the result set has duplicate column names. Otherwise the last of these columns silently wins.
+	// Default is false.
+	Strict bool
+
+	// ContextFieldResolver, if set, provides the values of fields tagged with `db:"name,ctx"` whose column isn't in
+	// the result set. It's called once per scan with the context of the scan and the column name of the field, and
+	// reports whether it has a value, which is then stored in the field of every row. If the column is in the result
+	// set, it's scanned as usual. Default is nil (such fields are left as is).
+	ContextFieldResolver func(ctx context.Context, column string) (any, bool)
+
+	// Logger, if set, receives a warning for every column that is ignored because of IgnoreUnknownColumns. Each
+	// column is reported once per struct type. A [log/slog.Logger] can be used. Default is nil (ignored columns aren't
+	// reported).
+	Logger Logger
+
+	// DenyColumns lists columns that are never scanned into a struct, even if a field maps to them. They're discarded,
+	// and not captured by an extra field either. AllowColumns, if not nil, lists the only columns that are scanned
+	// into a struct, the others are discarded. Both are matched after NormalizeColumn and StripColumnPrefix.
+	// Default is nil (all columns are scanned).
+	DenyColumns  []string
+	AllowColumns []string
+}
+
+// Logger receives warnings from a [Scanner]. args are alternating keys and values, like for [log/slog.Logger.Warn].
+type Logger interface {
+	Warn(msg string, args ...any)
 }
 
 // Scan is for scanning the result set from rows into a destination structure.
 // It supports scanning into a struct, a slice of structs, or a channel that emits structs.
 //
 // The destination (dest) must be a pointer to a struct, a pointer to a slice of structs, or a channel of structs.
+// It can also be a pointer to a slice of scalars (like []int or []string), if the result set has exactly one column.
 // If the destination is a channel, Scan will send a struct for each row in the result set until the context is canceled
-// or the result set is exhausted.
+// or the result set is exhausted. If the destination is a slice, Scan stops when the context is canceled, see also
+// [Scanner.PartialOnCancel].
 //
 // The structure of the destination struct must match the structure of the result set. The field name or its `db` tag must match the column name.
-// The field order does not need to match the column order. If a column has no corresponding struct field, Scan returns an error.
+// The field order does not need to match the column order. If a column has no corresponding struct field, Scan returns an error,
+// unless the struct has an extra field. See the package documentation for the struct tags and their options.
+//
+// Fields of type [sql.RawBytes] hold bytes owned by the driver when scanning into a single struct, these are only
+// valid until the next call to Next, Scan or Close on rows. When scanning into a slice or channel, they hold copies.
+//
+// The destination can also be a function of type func(*T) error, where T is a struct. It's called for every row,
+// with the same struct that's reset between rows, so it must not be retained. If it returns an error, Scan stops
+// and returns it.
+//
+// Options override the configuration of the Scanner for this call only.
 //
 // Scan blocks until the context is canceled, the result set is exhausted, or an error occurs.
-func (s *Scanner) Scan(ctx context.Context, rows Rows, dest any) error {
+func (s *Scanner) Scan(ctx context.Context, rows Rows, dest any, opts ...ScanOption) error {
+	if len(opts) == 0 {
+		return s.scan(ctx, rows, dest, s.options()) // the options don't escape to the heap through opt
+	}
+	o := s.options()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return s.scan(ctx, rows, dest, o)
+}
+
+// ScanClose is like Scan, but it closes rows afterwards, even if Scan fails. An error from Close is joined with
+// the error from Scan, if any. It replaces the usual deferred Close after a query.
+func (s *Scanner) ScanClose(ctx context.Context, rows RowsCloser, dest any, opts ...ScanOption) error {
+	err := s.Scan(ctx, rows, dest, opts...)
+	return errors.Join(err, rows.Close())
+}
+
+// ScanChecked is like Scan, but it returns an error instead of panicking on misuse, whether NoPanic is set or not.
+// An invalid destination is reported by returning ErrDestNil, ErrDestNotPointer or ErrDestElemNotStruct, other
+// misuse, like an invalid struct tag, by returning an error that wraps [ErrMisuse].
+func (s *Scanner) ScanChecked(ctx context.Context, rows Rows, dest any, opts ...ScanOption) (err error) {
+	if err := checkDest(dest); err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = misuseError(r)
+		}
+	}()
+	return s.Scan(ctx, rows, dest, opts...)
+}
+
+// ScanPrefix is like Scan, but it only scans the first k columns of the result set and discards the others. The k
+// columns are mapped like Scan does, by name, or by position if [Scanner.Positional] is set. ScanPrefix returns an
+// error if the result set has fewer than k columns. k must not be negative.
+func (s *Scanner) ScanPrefix(ctx context.Context, rows Rows, dest any, k int, opts ...ScanOption) (err error) {
+	defer s.recoverMisuse(&err)
+	if k < 0 {
+		panic("k must not be negative")
+	}
+	return s.Scan(ctx, &prefixRows{Rows: rows, k: k}, dest, opts...)
+}
+
+// MustScan is like Scan, but it panics if Scan returns an error.
+func (s *Scanner) MustScan(ctx context.Context, rows Rows, dest any, opts ...ScanOption) {
+	if err := s.Scan(ctx, rows, dest, opts...); err != nil {
+		panic(fmt.Errorf("sqlz: MustScan: %w", err))
+	}
+}
+
+// A ScanOption overrides the configuration of a Scanner for a single Scan call.
+type ScanOption func(*scanOptions)
+
+// WithIgnoreUnknownColumns overrides [Scanner.IgnoreUnknownColumns].
+func WithIgnoreUnknownColumns(ignore bool) ScanOption {
+	return func(o *scanOptions) {
+		o.ignoreUnknownColumns = ignore
+	}
+}
+
+// ScanColumns is like Scan, but it only scans the wanted columns into dest. All other columns in the result set
+// are discarded, regardless of whether dest has a field for them. Every wanted column must be present in the
+// result set and must have a corresponding struct field, otherwise ScanColumns returns an error. Like
+// [Scanner.DenyColumns], wanted columns are matched after NormalizeColumn and StripColumnPrefix.
+func (s *Scanner) ScanColumns(ctx context.Context, rows Rows, dest any, wanted []string) error {
+	if wanted == nil {
+		wanted = []string{}
+	}
+	opts := s.options()
+	opts.wanted = wanted
+	return s.scan(ctx, rows, dest, opts)
+}
+
+// ScanAppend is like Scan, but dest must be a pointer to a slice, and progress is called every given number of rows
+// with the number of rows appended so far. If the context is canceled, the slice keeps the rows appended so far only if [Scanner.PartialOnCancel] is set.
+func (s *Scanner) ScanAppend(ctx context.Context, rows Rows, dest any, every int, progress func(n int)) (err error) {
+	defer s.recoverMisuse(&err)
+	if every <= 0 {
+		panic("every must be positive")
+	}
+	if v := reflect.ValueOf(dest); v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Slice {
+		panic("dest must be a pointer to a slice")
+	}
+	opts := s.options()
+	opts.progress = progress
+	opts.progressEvery = every
+	return s.scan(ctx, rows, dest, opts)
+}
+
+// ScanWithNulls scans a single row into dest, which must be a pointer to a struct, and reports which columns were NULL.
+// Instead of returning an error, fields of NULL columns are left untouched, even if they can't hold NULL. They're
+// still reset if [Scanner.ZeroBeforeScan] is set, like the other fields. It's slower than Scan, because it scans
+// the row twice.
+func (s *Scanner) ScanWithNulls(ctx context.Context, rows Rows, dest any) (nullColumns []string, err error) {
+	defer s.recoverMisuse(&err)
 	destValue := reflect.ValueOf(dest)
-	if kind := destValue.Kind(); kind == reflect.Chan {
-		return s.scanChan(ctx, destValue, rows)
-	} else if kind != reflect.Pointer {
-		panic("dest must be a pointer or chan")
+	if destValue.Kind() != reflect.Pointer || destValue.Elem().Kind() != reflect.Struct {
+		panic("dest must be a pointer to a struct")
 	}
-	elemValue := destValue.Elem()
-	switch elemValue.Kind() {
-	case reflect.Struct:
-		destValues, err := s.mapFieldDest(elemValue, rows)
+	opts := s.options()
+	opts.ctx = ctx
+	err = s.scanStruct(ctx, destValue.Elem(), rows, &opts, func(p *plan) error {
+		columns, err := rows.Columns()
 		if err != nil {
 			return err
 		}
-		if !rows.Next() {
-			if err = rows.Err(); err != nil {
-				return err
+		sniff := make([]any, len(columns))
+		for i := range sniff {
+			sniff[i] = new(any)
+		}
+		if err = rows.Scan(sniff...); err != nil {
+			return err
+		}
+		for i, v := range sniff {
+			if *v.(*any) == nil {
+				nullColumns = append(nullColumns, columns[i])
+				p.values[i] = v
 			}
-			return sql.ErrNoRows
 		}
-		return rows.Scan(destValues...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nullColumns, nil
+}
+
+// scanOptions holds the effective options for a single scan.
+type scanOptions struct {
+	ignoreUnknownColumns bool
+	partialOnCancel      bool
+	internStrings        bool
+	stripColumnPrefix    string
+	closeChan            bool
+	zeroBeforeScan       bool
+	positional           bool
+	nullAsZero           bool
+	fallbackPositional   bool
+	profile              func(map[string]time.Duration)
+	normalizeColumn      func(string) string
+	wanted               []string    // if not nil, only these columns are scanned
+	progress             func(n int) // if not nil, called with the number of rows scanned into a slice, every progressEvery rows
+	progressEvery        int
+	location             *time.Location
+	sanitizeString       func([]byte) string
+	strict               bool
+	resolveField         func(context.Context, string) (any, bool)
+	logger               Logger
+	denyColumns          []string
+	allowColumns         []string
+	ctx                  context.Context // context of the scan, for resolveField
+}
+
+func (s *Scanner) options() scanOptions {
+	return scanOptions{
+		ignoreUnknownColumns: s.IgnoreUnknownColumns,
+		partialOnCancel:      s.PartialOnCancel,
+		internStrings:        s.InternStrings,
+		stripColumnPrefix:    s.StripColumnPrefix,
+		closeChan:            s.CloseChanOnDone,
+		zeroBeforeScan:       s.ZeroBeforeScan,
+		positional:           s.Positional,
+		nullAsZero:           s.NullAsZero,
+		fallbackPositional:   s.FallbackPositional,
+		profile:              s.ColumnProfile,
+		normalizeColumn:      s.NormalizeColumn,
+		location:             s.Location,
+		sanitizeString:       s.StringSanitize,
+		strict:               s.Strict,
+		resolveField:         s.ContextFieldResolver,
+		logger:               s.Logger,
+		denyColumns:          s.DenyColumns,
+		allowColumns:         s.AllowColumns,
+	}
+}
+
+func (s *Scanner) scan(ctx context.Context, rows Rows, dest any, opts scanOptions) (err error) {
+	defer s.recoverMisuse(&err)
+	opts.ctx = ctx
+	destValue := reflect.ValueOf(dest)
+	if kind := destValue.Kind(); kind == reflect.Chan {
+		return s.scanChan(ctx, destValue, rows, &opts)
+	} else if kind == reflect.Func {
+		return s.scanFunc(ctx, destValue, rows, &opts)
+	} else if kind != reflect.Pointer {
+		panic("dest must be a pointer, chan or func")
+	}
+	elemValue := destValue.Elem()
+	switch elemValue.Kind() {
+	case reflect.Struct:
+		return s.scanStruct(ctx, elemValue, rows, &opts, nil)
 
 	case reflect.Slice:
-		return s.scanSlice(destValue.Elem(), rows)
+		return s.scanSlice(ctx, destValue.Elem(), rows, &opts)
 
 	default:
 		panic("dest must point to a struct or slice")
 	}
 }
 
-func (s *Scanner) scanSlice(dest reflect.Value, rows Rows) error {
-	elemType := dest.Type().Elem()
-	isPtrElem := elemType.Kind() == reflect.Pointer
-	if isPtrElem {
-		elemType = elemType.Elem()
+// scanStruct scans the first row of rows into the struct dest. If before isn't nil, it's called with the plan once
+// the row is fetched, before it's scanned.
+func (s *Scanner) scanStruct(ctx context.Context, dest reflect.Value, rows Rows, opts *scanOptions, before func(*plan) error) error {
+	p, err := s.mapFieldDest(dest, rows, opts)
+	if err != nil {
+		return err
 	}
-	if elemType.Kind() != reflect.Struct {
-		panic("dest slice of non-struct elements")
+	defer p.release()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if before != nil {
+		if err = before(p); err != nil {
+			return err
+		}
+	}
+	if opts.zeroBeforeScan {
+		dest.SetZero()
+	}
+	return p.scan(rows)
+}
+
+func (s *Scanner) scanSlice(ctx context.Context, dest reflect.Value, rows Rows, opts *scanOptions) error {
+	var (
+		elemType  = dest.Type().Elem()
+		isPtrElem bool
+		elem      reflect.Value
+		p         *plan
+		err       error
+	)
+	if s.isScalar(elemType) {
+		// Pointer elements are scanned directly, so they can be nil.
+		elem = reflect.New(elemType).Elem()
+		p, err = s.mapScalarDest(elem, rows)
+	} else {
+		isPtrElem = elemType.Kind() == reflect.Pointer
+		if isPtrElem {
+			elemType = elemType.Elem()
+		}
+		elem = reflect.New(elemType).Elem()
+		p, err = s.mapFieldDest(elem, rows, opts)
 	}
-	elem := reflect.New(elemType).Elem()
-	destValues, err := s.mapFieldDest(elem, rows)
 	if err != nil {
 		return err
 	}
+	defer p.release()
+	p.ownRawBytes = true
 	dlen, dcap := dest.Len(), dest.Cap()
-	for rows.Next() {
-		if err := rows.Scan(destValues...); err != nil {
-			return err
-		}
+	origLen := dlen
+	err = scanRows(ctx, rows, p, elem, func() error {
 		newElem := elem
 		if isPtrElem {
 			newElem = reflect.New(elemType)
@@ -92,16 +428,21 @@ func (s *Scanner) scanSlice(dest reflect.Value, rows Rows) error {
 		dest.SetLen(dlen + 1)
 		dest.Index(dlen).Set(newElem)
 		dlen++
-		// Resetting the elem to zero is needed to handle null cells correctly.
-		elem.SetZero()
-	}
-	if err = rows.Err(); err != nil {
-		return err
+		if opts.progress != nil && (dlen-origLen)%opts.progressEvery == 0 {
+			opts.progress(dlen - origLen)
+		}
+		return nil
+	})
+	if err != nil && err == ctx.Err() && !opts.partialOnCancel {
+		for i := origLen; i < dlen; i++ {
+			dest.Index(i).SetZero()
+		}
+		dest.SetLen(origLen)
 	}
-	return nil
+	return err
 }
 
-func (s *Scanner) scanChan(ctx context.Context, dest reflect.Value, rows Rows) error {
+func (s *Scanner) scanChan(ctx context.Context, dest reflect.Value, rows Rows, opts *scanOptions) error {
 	elemType := dest.Type().Elem()
 	isPtrElem := elemType.Kind() == reflect.Pointer
 	if isPtrElem {
@@ -110,11 +451,16 @@ func (s *Scanner) scanChan(ctx context.Context, dest reflect.Value, rows Rows) e
 	if elemType.Kind() != reflect.Struct {
 		panic("dest chan of non-struct elements")
 	}
+	if opts.closeChan {
+		defer dest.Close()
+	}
 	elem := reflect.New(elemType).Elem()
-	destValues, err := s.mapFieldDest(elem, rows)
+	p, err := s.mapFieldDest(elem, rows, opts)
 	if err != nil {
 		return err
 	}
+	defer p.release()
+	p.ownRawBytes = true
 	selectOps := []reflect.SelectCase{
 		{
 			Dir:  reflect.SelectSend,
@@ -125,10 +471,7 @@ func (s *Scanner) scanChan(ctx context.Context, dest reflect.Value, rows Rows) e
 			Chan: reflect.ValueOf(ctx.Done()),
 		},
 	}
-	for rows.Next() {
-		if err := rows.Scan(destValues...); err != nil {
-			return err
-		}
+	return scanRows(ctx, rows, p, elem, func() error {
 		newElem := elem
 		if isPtrElem {
 			newElem = reflect.New(elemType)
@@ -139,41 +482,232 @@ func (s *Scanner) scanChan(ctx context.Context, dest reflect.Value, rows Rows) e
 			// select on ctx.Done()
 			return ctx.Err()
 		}
-		// Resetting the elem to zero is needed to handle null cells correctly.
-		elem.SetZero()
+		return nil
+	})
+}
+
+// scanFunc calls the visitor dest for every row.
+func (s *Scanner) scanFunc(ctx context.Context, dest reflect.Value, rows Rows, opts *scanOptions) error {
+	if !isVisitor(dest.Type()) {
+		panic("dest func must be of type func(*T) error, where T is a struct")
+	}
+	elem := reflect.New(dest.Type().In(0).Elem())
+	p, err := s.mapFieldDest(elem.Elem(), rows, opts)
+	if err != nil {
+		return err
+	}
+	defer p.release()
+	args := []reflect.Value{elem}
+	return scanRows(ctx, rows, p, elem.Elem(), func() error {
+		if out := dest.Call(args); !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+		return nil
+	})
+}
+
+// scanRows scans the remaining rows of rows into the destinations of p, and calls each after every row. It checks
+// ctx before every row, and returns its error if it's done. Scan errors are wrapped in a [RowError]. elem, if valid,
+// is the value that p scans into.
+func scanRows(ctx context.Context, rows Rows, p *plan, elem reflect.Value, each func() error) error {
+	var (
+		done = ctx.Done()
+		row  = 0
+	)
+	for rows.Next() {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+		row++
+		if err := p.scan(rows); err != nil {
+			return &RowError{row, err}
+		}
+		if err := each(); err != nil {
+			return err
+		}
+		if elem.IsValid() {
+			// Resetting the elem to zero is needed to handle null cells correctly.
+			elem.SetZero()
+		}
 	}
 	return rows.Err()
 }
 
-func (s *Scanner) mapFieldDest(dest reflect.Value, rows Rows) ([]any, error) {
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, err
+// isVisitor reports whether t is of type func(*T) error, where T is a struct.
+func isVisitor(t reflect.Type) bool {
+	return t.NumIn() == 1 && t.In(0).Kind() == reflect.Pointer && t.In(0).Elem().Kind() == reflect.Struct &&
+		t.NumOut() == 1 && t.Out(0) == errorType
+}
+
+func (s *Scanner) structInfo(t reflect.Type) *structInfo {
+	maxDepth := s.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxDepth
 	}
-	fieldIndex := s.tc.getStructFieldIndex(dest.Type())
-	scanValues := make([]any, len(columns))
-	placeholder := new(any)
-	for i, column := range columns {
-		x, ok := fieldIndex[column]
-		if ok {
-			scanValues[i] = fieldByIndex(dest, x).Addr().Interface()
-		} else if !s.IgnoreUnknownColumns {
-			return nil, fmt.Errorf("sqlz: missing field mapping for column %q", column)
-		} else {
-			scanValues[i] = placeholder
+	return s.tc.getStructInfo(t, &indexOptions{
+		prefixSeparator: s.PrefixSeparator,
+		tagPriority:     s.TagPriority,
+		dialect:         s.Dialect,
+		maxDepth:        maxDepth,
+	})
+}
+
+// Warm adds the struct types of the given samples to the internal type cache, so the first Scan into them
+// doesn't have to build their field index. Samples can be anything Scan accepts, or struct values, like User{}.
+func (s *Scanner) Warm(samples ...any) {
+	for _, sample := range samples {
+		t, err := destStructType(sample)
+		if err != nil {
+			panic(err)
 		}
+		s.structInfo(t)
+	}
+}
+
+// RegisterType sets the column names of the fields of the struct type t, by field name. These take precedence over
+// the `db` tags and the default names of the fields, a column name of "-" skips the field. Fields that aren't in
+// mapping are mapped as usual.
+//
+// RegisterType purges the internal type cache, it should be called before the Scanner is used.
+func (s *Scanner) RegisterType(t reflect.Type, mapping map[string]string) {
+	if t.Kind() != reflect.Struct {
+		panic("RegisterType requires a struct type")
 	}
-	return scanValues, nil
+	s.tc.register(t, mapping)
 }
 
+// RegisterKind makes fields of type t, or pointers to it, scan through decode. decode receives the value from the
+// database (src), which is NULL if nil, and stores it in dst, which holds the field. Tag options, like json, take
+// precedence over registered kinds.
+//
+// RegisterKind purges the internal type cache, it should be called before the Scanner is used.
+func (s *Scanner) RegisterKind(t reflect.Type, decode func(src any, dst reflect.Value) error) {
+	s.tc.registerKind(t, decode)
+}
+
+// defaultMaxDepth is the default of [Scanner.MaxDepth].
+const defaultMaxDepth = 10
+
 // PurgeCache purges the internal type cache.
 func (s *Scanner) PurgeCache() {
 	s.tc.purge()
 }
 
+// CacheStats contains statistics about the internal type cache of a Scanner.
+type CacheStats struct {
+	Types  int    // number of types in the cache, once for each set of options they were indexed with
+	Hits   uint64 // number of lookups of a cached type
+	Misses uint64 // number of lookups that had to add a type to the cache
+}
+
+// CacheStats returns statistics about the internal type cache. A steadily increasing number of misses
+// indicates that new types keep appearing, which causes the whole cache to be copied each time.
+func (s *Scanner) CacheStats() CacheStats {
+	return s.tc.stats()
+}
+
+// ErrMisuse is wrapped by the errors that report misuse, if [Scanner.NoPanic] is set.
+var ErrMisuse = errors.New("sqlz: misuse")
+
+// Errors returned by [Scanner.ScanChecked] for invalid destinations.
+var (
+	ErrDestNil           = errors.New("sqlz: dest is nil")
+	ErrDestNotPointer    = errors.New("sqlz: dest must be a pointer, chan or func")
+	ErrDestElemNotStruct = errors.New("sqlz: dest must point to a struct or slice, be a chan of structs, or a func(*T) error")
+)
+
+// recoverMisuse recovers a panic caused by misuse and stores it in err as an error, if NoPanic is set.
+// It must be deferred. Misuse panics with a string, other panics are not recovered.
+func (s *Scanner) recoverMisuse(err *error) {
+	if !s.NoPanic {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = misuseError(r)
+	}
+}
+
+// misuseError returns the error for the recovered panic r, which must be caused by misuse. Otherwise it panics again.
+func misuseError(r any) error {
+	msg, ok := r.(string)
+	if !ok {
+		panic(r)
+	}
+	return fmt.Errorf("%w: %s", ErrMisuse, msg)
+}
+
+// checkDest returns an error if dest isn't a valid destination for Scan.
+func checkDest(dest any) error {
+	v := reflect.ValueOf(dest)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return ErrDestNil
+	case reflect.Chan:
+		t := v.Type().Elem()
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return ErrDestElemNotStruct
+		}
+		return nil
+	case reflect.Func:
+		if v.IsNil() {
+			return ErrDestNil
+		}
+		if !isVisitor(v.Type()) {
+			return ErrDestElemNotStruct
+		}
+		return nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ErrDestNil
+		}
+		if k := v.Elem().Kind(); k != reflect.Struct && k != reflect.Slice {
+			return ErrDestElemNotStruct
+		}
+		return nil
+	}
+	return ErrDestNotPointer
+}
+
+// TrimQuotes removes the surrounding whitespace and quotes (" or `) from column. It can be used as
+// [Scanner.NormalizeColumn].
+func TrimQuotes(column string) string {
+	column = strings.TrimSpace(column)
+	if n := len(column); n >= 2 && column[0] == column[n-1] && (column[0] == '"' || column[0] == '`') {
+		column = column[1 : n-1]
+	}
+	return column
+}
+
+// RowError is returned when scanning a row into a slice or channel fails. It identifies the row.
+type RowError struct {
+	Row int   // number of the row in the result set, starting at 1
+	Err error // error that occurred while scanning the row
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("sqlz: scan error at row %d: %v", e.Row, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
 // fieldByIndex has the same functionality as [reflect.Value.FieldByIndex] but uses uint16's as indexes.
+// If a pointer to struct on the way is nil, it returns the zero value of the field, which isn't settable.
 func fieldByIndex(v reflect.Value, index []uint16) reflect.Value {
-	for _, i := range index {
+	for n, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Zero(fieldTypeByIndex(v.Type(), index[n:]))
+			}
+			v = v.Elem()
+		}
 		v = v.Field(int(i))
 	}
 	return v
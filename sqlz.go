@@ -1,9 +1,45 @@
 // Package sqlz provides a set of helper functions and types to simplify operations with SQL databases in Go.
 // It provides a more flexible and intuitive interface for scanning SQL query results directly into Go structs,
 // slices of structs, or channels of structs.
+//
+// # Rows
+//
+// The scanning functions accept any implementation of [Rows], not only [sql.Rows]. In tests, rows can come from
+// a mocking driver like go-sqlmock, whose *sql.Rows are scanned like any other, or from a small in-memory
+// implementation, see the example of Rows. sqlz itself doesn't depend on a mocking driver, so it has no
+// dependencies outside the standard library.
+//
+// # Struct tags
+//
+// The `db` tag of a field holds the name of its column, followed by options, like `db:"created_at,epoch"`. A name
+// of "-" skips the field, and a name like "#2" maps the field to the third column, whatever its name. The options
+// are:
+//
+//   - extra: a map[string]any field receives the columns that have no corresponding field.
+//   - rest: like extra, but a json.RawMessage or map[string]any field receives them as a JSON object.
+//   - setter=SetName: the field is set through the given method of its struct, which also works for unexported
+//     fields.
+//   - epoch, epoch_ms: a time.Time field is scanned from a Unix timestamp in seconds or milliseconds, and an int64
+//     field receives the Unix timestamp of a timestamp column.
+//   - rownum: an integer field receives the number of the row in the result set, starting at 1.
+//   - json: the field is decoded from a JSON document, using [encoding/json.Unmarshal].
+//   - hstore: a map[string]string or map[string]*string field is parsed from a Postgres hstore.
+//   - pgarray: a slice of strings, numbers or bools is parsed from a one-dimensional Postgres array.
+//   - ctx: the field is filled from the context if its column isn't in the result set, see
+//     [Scanner.ContextFieldResolver].
+//   - nested=parentkey:childkey: a slice field is filled with the rows of a child query, see [Scanner.ScanNested].
+//
+// Fields of the same struct and type that are tagged with the same name all receive the column, each with its own
+// copy of slices, maps and pointers. The struct of a pointer field tagged with a name is traversed like an embedded
+// struct, see [Scanner.PrefixSeparator]. It's only allocated if at least one of its columns isn't NULL, otherwise
+// the field is set to nil.
 package sqlz
 
-import "context"
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+)
 
 // Rows represents the result set of a database query.
 // It's implemented by [sql.Rows].
@@ -14,17 +50,66 @@ type Rows interface {
 	Scan(dest ...any) error
 }
 
-var global Scanner
+// RowsCloser is a [Rows] that must be closed after use, like [sql.Rows].
+type RowsCloser interface {
+	Rows
+	Close() error
+}
+
+// Decoder is implemented by types that decode themselves from a database value, like [sql.Scanner] does.
+// It's for existing types that can't implement sql.Scanner. Fields whose pointer implements Decoder are
+// scanned by calling DecodeSQL with the value of the column, unless the pointer also implements sql.Scanner.
+type Decoder interface {
+	DecodeSQL(src any) error
+}
+
+var global atomic.Pointer[Scanner]
+
+func init() {
+	global.Store(new(Scanner))
+}
+
+// Default returns the global Scanner, which is used by the package-level functions.
+func Default() *Scanner {
+	return global.Load()
+}
+
+// SetDefault makes s the global Scanner, which is used by the package-level functions.
+// The Scanner must not be modified afterwards.
+func SetDefault(s *Scanner) {
+	if s == nil {
+		panic("sqlz: SetDefault called with nil Scanner")
+	}
+	global.Store(s)
+}
 
 // Scan is for scanning the result set from rows into a destination structure.
 // It uses the global Scanner. See [Scanner.Scan] for more details.
-func Scan(ctx context.Context, rows Rows, dest any) error {
-	return global.Scan(ctx, rows, dest)
+func Scan(ctx context.Context, rows Rows, dest any, opts ...ScanOption) error {
+	return Default().Scan(ctx, rows, dest, opts...)
+}
+
+// RegisterGlobalKind makes fields of type t, or pointers to it, scan through decode in all Scanners, including the
+// global one. Kinds registered on a Scanner with [Scanner.RegisterKind] take precedence. See [Scanner.RegisterKind]
+// for more details.
+//
+// RegisterGlobalKind doesn't purge the internal type cache of existing Scanners, it should be called during
+// initialization, before any Scanner is used.
+func RegisterGlobalKind(t reflect.Type, decode func(src any, dst reflect.Value) error) {
+	globalKinds.mu.Lock()
+	defer globalKinds.mu.Unlock()
+	globalKinds.m.Store(globalKinds.m.Load().with(t, decode))
+}
+
+// ScanClose scans the result set from rows into dest, and closes rows afterwards, using the global Scanner.
+// See [Scanner.ScanClose] for more details.
+func ScanClose(ctx context.Context, rows RowsCloser, dest any, opts ...ScanOption) error {
+	return Default().ScanClose(ctx, rows, dest, opts...)
 }
 
 // PurgeCache purges the internal type cache of the global Scanner.
 //
-// Deprecated: This is a no-op, use a dedicated [Scanner] instead.
+// Deprecated: This is a no-op, use a dedicated [Scanner] or [Default] instead.
 func PurgeCache() {
 	// no-op
 }
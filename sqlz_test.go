@@ -1,13 +1,30 @@
 package sqlz_test
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/semrekkers/sqlz"
 	"github.com/semrekkers/sqlz/internal/scantest"
+	"github.com/semrekkers/sqlz/sqlztest"
 )
 
 type testStructBase struct {
@@ -55,128 +72,3031 @@ func TestScanStruct(t *testing.T) {
 	}
 }
 
+func TestScanStructNoRows(t *testing.T) {
+	var (
+		// Unlike scantest.NewRows(0), real rows have valid columns for an empty result set.
+		rows   = scantest.Query(t, []string{"id", "username"})
+		record testStructBase
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("err{%v} != sql.ErrNoRows", err)
+	}
+}
+
+// TestScanRowsInterfaceOnly makes sure that Scan only uses the methods of the Rows interface, so it works with any
+// implementation, like the *sql.Rows of mocking drivers such as go-sqlmock.
+func TestScanRowsInterfaceOnly(t *testing.T) {
+	var (
+		rows = struct{ sqlz.Rows }{scantest.Query(t, []string{"id", "username"},
+			[]driver.Value{int64(1), "john_doe"},
+			[]driver.Value{int64(2), "jane_doe"},
+		)}
+		records []testStructBase
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if len(records) != 2 || records[1].Username != "jane_doe" {
+		t.Errorf("records %v != [{1 john_doe} {2 jane_doe}]", records)
+	}
+}
+
 func TestScanMissingField(t *testing.T) {
 	var (
-		rows   = scantest.NewRows(1)
-		record testStructBase
+		rows   = scantest.NewRows(1)
+		record testStructBase
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err.Error() != `sqlz: missing field mapping for column "created_at"` {
+		t.Errorf("err{%s} != `sqlz: missing field mapping ...`", err)
+	}
+}
+
+func TestScanIgnoreUnknownColumns(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
+		rows   = scantest.NewRows(1)
+		record testStructBase
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+}
+
+func TestScanColumns(t *testing.T) {
+	var (
+		sc     sqlz.Scanner
+		rows   = scantest.NewRows(1)
+		record testStructBase
+	)
+
+	err := sc.ScanColumns(context.Background(), rows, &record, []string{"email", "age"})
+
+	if err != nil {
+		t.Error("sc.ScanColumns(...):", err)
+	}
+	want := testStructBase{Email: "john@example.com", Age: 42}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("record %v != %v", record, want)
+	}
+}
+
+func TestScanColumnsStripColumnPrefix(t *testing.T) {
+	var (
+		sc   = sqlz.Scanner{StripColumnPrefix: "."}
+		rows = scantest.Query(t, []string{"u.email", "u.age", "u.username"},
+			[]driver.Value{"john@example.com", int64(42), "john_doe"},
+		)
+		record testStructBase
+	)
+
+	err := sc.ScanColumns(context.Background(), rows, &record, []string{"email", "age"})
+
+	if err != nil {
+		t.Error("sc.ScanColumns(...):", err)
+	}
+	want := testStructBase{Email: "john@example.com", Age: 42}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("record %v != %v", record, want)
+	}
+}
+
+func TestScanColumnsMissingColumn(t *testing.T) {
+	var (
+		sc     sqlz.Scanner
+		rows   = scantest.NewRows(1)
+		record testStructBase
+	)
+
+	err := sc.ScanColumns(context.Background(), rows, &record, []string{"age", "nickname"})
+
+	if err == nil || err.Error() != `sqlz: wanted column "nickname" is not in the result set` {
+		t.Errorf("err{%v} != `sqlz: wanted column ...`", err)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	var (
+		prev   = sqlz.Default()
+		rows   = scantest.NewRows(1)
+		record testStructBase
+	)
+	sqlz.SetDefault(&sqlz.Scanner{IgnoreUnknownColumns: true})
+	defer sqlz.SetDefault(prev)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+}
+
+func TestEmbeddedFieldShadowing(t *testing.T) {
+	type contact struct {
+		Email string
+		Phone string
+	}
+	var (
+		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
+		rows   = scantest.NewRows(1)
+		record struct {
+			contact
+			Email string
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if record.Email != "john@example.com" {
+		t.Errorf("record.Email{%s} != john@example.com", record.Email)
+	}
+	if record.contact.Email != "" {
+		t.Errorf("record.contact.Email{%s} != \"\"", record.contact.Email)
+	}
+}
+
+func TestEmbeddedFieldAmbiguous(t *testing.T) {
+	type contact struct {
+		Email string
+	}
+	type account struct {
+		Email string
+	}
+	var (
+		rows   = scantest.NewRows(1)
+		record struct {
+			testStruct
+			contact
+			account
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err == nil || err.Error() != `sqlz: missing field mapping for column "email"` {
+		t.Errorf("err{%v} != `sqlz: missing field mapping ...`", err)
+	}
+}
+
+func TestScanColumnIntoFields(t *testing.T) {
+	type price struct {
+		Amount int64 `db:"price"`
+	}
+	var (
+		rows   = scantest.Query(t, []string{"id", "price"}, []driver.Value{int64(1), int64(995)})
+		record struct {
+			ID int
+			price
+			Cents int64 `db:"price"`
+			Raw   int64 `db:"price"`
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if record.Cents != 995 || record.Raw != 995 || record.Amount != 0 {
+		t.Errorf("record %+v != {ID:1 Amount:0 Cents:995 Raw:995}", record)
+	}
+
+	var blobs struct {
+		Data []byte `db:"data"`
+		Copy []byte `db:"data"`
+	}
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"data"}, []driver.Value{[]byte("abc")}), &blobs)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	blobs.Copy[0] = 'x'
+	if string(blobs.Data) != "abc" {
+		t.Errorf("blobs.Data %q != abc, it shares memory with its copy", blobs.Data)
+	}
+}
+
+func TestScanExtraField(t *testing.T) {
+	var (
+		rows    = scantest.NewRows(2)
+		records []struct {
+			ID       int
+			Username string
+			Extra    map[string]any `db:",extra"`
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records){%d} != 2", len(records))
+	}
+	want := map[string]any{
+		"display_name": "John Doe",
+		"email":        "john@example.com",
+		"age":          42,
+		"is_admin":     false,
+		"created_at":   fixedTestStruct.CreatedAt,
+	}
+	for i, rec := range records {
+		if rec.ID != 1146 || rec.Username != "john_doe" {
+			t.Errorf("record[%d] %v has wrong mapped fields", i, rec)
+		}
+		if !reflect.DeepEqual(rec.Extra, want) {
+			t.Errorf("record[%d].Extra %v != %v", i, rec.Extra, want)
+		}
+	}
+	records[0].Extra["age"] = 43
+	if records[1].Extra["age"] != 42 {
+		t.Error("records share the same Extra map")
+	}
+}
+
+func TestScanRestField(t *testing.T) {
+	var (
+		rows = scantest.Query(t, []string{"id", "color", "size", "note"},
+			[]driver.Value{int64(1), []byte("red"), int64(42), nil},
+		)
+		record struct {
+			ID   int
+			Rest json.RawMessage `db:",rest"`
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if want := `{"color":"red","note":null,"size":42}`; record.ID != 1 || string(record.Rest) != want {
+		t.Errorf("record {%d %s} != {1 %s}", record.ID, record.Rest, want)
+	}
+}
+
+func TestPrefixSeparator(t *testing.T) {
+	type Address struct {
+		City string
+		Zip  string
+	}
+	var (
+		sc   = sqlz.Scanner{PrefixSeparator: "_"}
+		rows = scantest.Query(t, []string{"id", "address_city", "address_zip"},
+			[]driver.Value{int64(1), "Amsterdam", "1011 AB"},
+		)
+		record struct {
+			ID      int
+			Address `db:"address"`
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	want := Address{City: "Amsterdam", Zip: "1011 AB"}
+	if record.Address != want {
+		t.Errorf("record.Address %v != %v", record.Address, want)
+	}
+}
+
+func TestScanWithNulls(t *testing.T) {
+	var (
+		sc   sqlz.Scanner
+		rows = scantest.Query(t, []string{"id", "nickname", "age"},
+			[]driver.Value{int64(1), nil, nil},
+		)
+		record = struct {
+			ID       int
+			Nickname string
+			Age      int
+		}{Age: 42}
+	)
+
+	nullColumns, err := sc.ScanWithNulls(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sc.ScanWithNulls(...):", err)
+	}
+	if want := []string{"nickname", "age"}; !reflect.DeepEqual(nullColumns, want) {
+		t.Errorf("nullColumns %v != %v", nullColumns, want)
+	}
+	if record.ID != 1 || record.Age != 42 {
+		t.Errorf("record %v has wrong fields", record)
+	}
+
+	sc.ZeroBeforeScan = true
+	_, err = sc.ScanWithNulls(context.Background(), scantest.Query(t, []string{"id", "age"}, []driver.Value{int64(2), nil}), &record)
+
+	if err != nil || record.ID != 2 || record.Age != 0 {
+		t.Errorf("record %v != {2  0} or err{%v} != nil", record, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sc.ScanWithNulls(ctx, scantest.Query(t, []string{"id"}, []driver.Value{int64(3)}), &record)
+
+	if err != context.Canceled || record.ID != 2 {
+		t.Errorf("err{%v} != context.Canceled or record.ID %d != 2", err, record.ID)
+	}
+}
+
+func TestGetPtr(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
+	var (
+		ctx     = context.Background()
+		columns = []string{"id", "name"}
+	)
+
+	record, err := sqlz.GetPtr[user](ctx, scantest.Open(t, columns, []driver.Value{int64(1), "John"}), "")
+
+	if err != nil {
+		t.Error("sqlz.GetPtr(...):", err)
+	}
+	if record == nil || *record != (user{1, "John"}) {
+		t.Errorf("record %v != &{1 John}", record)
+	}
+
+	record, err = sqlz.GetPtr[user](ctx, scantest.Open(t, columns), "")
+
+	if record != nil || err != nil {
+		t.Errorf("record, err {%v, %v} != nil, nil", record, err)
+	}
+}
+
+func TestScanBool(t *testing.T) {
+	var (
+		sources = []driver.Value{true, int64(1), []byte{0x01}, []byte("1"), []byte("true"), "1", "true", false, int64(0), []byte{0x00}, []byte("0"), "false"}
+		values  = make([][]driver.Value, len(sources))
+		records []struct {
+			Bool    bool
+			BoolPtr *bool `db:"bool_ptr"`
+		}
+	)
+	for i, src := range sources {
+		values[i] = []driver.Value{src, src}
+	}
+	rows := scantest.Query(t, []string{"bool", "bool_ptr"}, append(values, []driver.Value{false, nil})...)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if len(records) != len(sources)+1 {
+		t.Fatalf("len(records){%d} != %d", len(records), len(sources)+1)
+	}
+	for i, rec := range records[:len(sources)] {
+		want := i < 7
+		if rec.Bool != want || rec.BoolPtr == nil || *rec.BoolPtr != want {
+			t.Errorf("records[%d] from %#v != %t", i, sources[i], want)
+		}
+	}
+	if records[len(sources)].BoolPtr != nil {
+		t.Error("BoolPtr from NULL != nil")
+	}
+}
+
+func TestJSONLinesRows(t *testing.T) {
+	var (
+		input = `{"id": 1, "name": "John", "is_admin": true, "tags": ["a"]}
+{"id": 2, "name": "Jane", "is_admin": 0}
+{"id": 3, "is_admin": false}
+`
+		rows    = sqlz.JSONLinesRows(strings.NewReader(input), []string{"id", "name", "is_admin"})
+		records []struct {
+			ID      int
+			Name    *string
+			IsAdmin bool `db:"is_admin"`
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("len(records){%d} != 3", len(records))
+	}
+	if rec := records[0]; rec.ID != 1 || rec.Name == nil || *rec.Name != "John" || !rec.IsAdmin {
+		t.Errorf("records[0] %v is wrong", rec)
+	}
+	if rec := records[1]; rec.ID != 2 || rec.Name == nil || *rec.Name != "Jane" || rec.IsAdmin {
+		t.Errorf("records[1] %v is wrong", rec)
+	}
+	if rec := records[2]; rec.ID != 3 || rec.Name != nil {
+		t.Errorf("records[2] %v is wrong", rec)
+	}
+}
+
+func TestJSONLinesRowsNull(t *testing.T) {
+	for _, input := range []string{`{"id": 1, "age": null}`, `{"id": 1}`} {
+		var (
+			sc     = sqlz.Scanner{NullAsZero: true}
+			rows   = sqlz.JSONLinesRows(strings.NewReader(input), []string{"id", "age"})
+			record struct{ ID, Age int }
+		)
+
+		err := sqlz.Scan(context.Background(), rows, &record)
+
+		if err == nil || err.Error() != `sqlz: scan column "age": converting NULL to int is unsupported` {
+			t.Errorf("%s: err{%v} != `sqlz: scan column \"age\": converting NULL ...`", input, err)
+		}
+
+		rows = sqlz.JSONLinesRows(strings.NewReader(input), []string{"id", "age"})
+		err = sc.Scan(context.Background(), rows, &record)
+
+		if err != nil || record.ID != 1 || record.Age != 0 {
+			t.Errorf("%s: record %v, err{%v} != {1 0}, <nil>", input, record, err)
+		}
+	}
+}
+
+func TestJSONLinesRowsInvalid(t *testing.T) {
+	var (
+		rows    = sqlz.JSONLinesRows(strings.NewReader(`{"id": 1} {"id": `), []string{"id"})
+		records []struct{ ID int }
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("err{%v} != io.ErrUnexpectedEOF", err)
+	}
+}
+
+type account struct {
+	id      int   `db:"id,setter=SetID"`
+	balance int64 `db:"balance,setter=SetBalance"`
+}
+
+func (a *account) SetID(v int) {
+	a.id = v
+}
+
+func (a *account) SetBalance(v int64) error {
+	if v < 0 {
+		return errors.New("negative balance")
+	}
+	a.balance = v
+	return nil
+}
+
+func TestScanSetter(t *testing.T) {
+	var (
+		rows = scantest.Query(t, []string{"id", "balance"},
+			[]driver.Value{int64(1), int64(100)},
+			[]driver.Value{int64(2), int64(-5)},
+		)
+		records []account
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err == nil || err.Error() != "sqlz: scan error at row 2: negative balance" {
+		t.Errorf("err{%v} != negative balance", err)
+	}
+	if len(records) != 1 || records[0] != (account{1, 100}) {
+		t.Errorf("records %v != [{1 100}]", records)
+	}
+}
+
+func TestScanInvalidSetter(t *testing.T) {
+	var (
+		rows   = scantest.NewRows(1)
+		record struct {
+			age int `db:"age,setter=SetAge"`
+		}
+	)
+
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.HasPrefix(msg, "setter SetAge of field age not found") {
+			t.Errorf("panic{%s} != setter SetAge ... not found", msg)
+		}
+	}()
+
+	sqlz.Scan(context.Background(), rows, &record)
+}
+
+func TestStripColumnPrefix(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{StripColumnPrefix: "."}
+		rows   = scantest.Query(t, []string{"users.id", "users.name", "age"}, []driver.Value{int64(1), "John", int64(42)})
+		record struct {
+			ID   int
+			Name string
+			Age  int
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if record.ID != 1 || record.Name != "John" || record.Age != 42 {
+		t.Errorf("record %v != {1 John 42}", record)
+	}
+}
+
+func TestNormalizeColumn(t *testing.T) {
+	var (
+		sc = sqlz.Scanner{
+			NormalizeColumn:   sqlz.TrimQuotes,
+			StripColumnPrefix: ".",
+		}
+		rows   = scantest.Query(t, []string{`"id"`, " username ", "`users.email`"}, []driver.Value{int64(1), "john_doe", "john@example.com"})
+		record testStructBase
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if record.ID != 1 || record.Username != "john_doe" || record.Email != "john@example.com" {
+		t.Errorf("record %v != {1 john_doe john@example.com}", record)
+	}
+}
+
+func TestZeroBeforeScan(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+		Age  int
+	}
+	var (
+		sc     = sqlz.Scanner{ZeroBeforeScan: true}
+		ctx    = context.Background()
+		record user
+	)
+
+	if err := sc.Scan(ctx, scantest.Query(t, []string{"id", "name"}, []driver.Value{int64(1), "John"}), &record); err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	record.Age = 42
+	if err := sc.Scan(ctx, scantest.Query(t, []string{"id"}, []driver.Value{int64(2)}), &record); err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+
+	if want := (user{ID: 2}); record != want {
+		t.Errorf("record %v != %v", record, want)
+	}
+}
+
+func TestPositional(t *testing.T) {
+	type base struct {
+		ID int
+	}
+	var (
+		sc   = sqlz.Scanner{Positional: true}
+		rows = scantest.Query(t, []string{"?column?", "?column?", "?column?"},
+			[]driver.Value{int64(1), "x", int64(42)},
+		)
+		record struct {
+			base
+			Name  string
+			Count int `db:"n"`
+			skip  string
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if record.ID != 1 || record.Name != "x" || record.Count != 42 {
+		t.Errorf("record %v != {{1} x 42}", record)
+	}
+
+	err = sc.Scan(context.Background(), scantest.Query(t, []string{"a", "b"}, []driver.Value{int64(1), "x"}), &record)
+
+	if err == nil || !strings.Contains(err.Error(), "requires 3 columns, not 2") {
+		t.Errorf("err{%v} != column count mismatch", err)
+	}
+}
+
+func TestFallbackPositional(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{FallbackPositional: true}
+		rows   = scantest.Query(t, []string{"count(*)", "id", "max(price)"}, []driver.Value{int64(3), int64(1), float64(9.5)})
+		record struct {
+			ID    int
+			Count int
+			Max   float64
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if record.ID != 1 || record.Count != 3 || record.Max != 9.5 {
+		t.Errorf("record %+v != {ID:1 Count:3 Max:9.5}", record)
+	}
+
+	rows = scantest.Query(t, []string{"count(*)", "id"}, []driver.Value{int64(3), int64(1)})
+	err = sc.Scan(context.Background(), rows, &record)
+
+	if err == nil || err.Error() != `sqlz: missing field mapping for column "count(*)"` {
+		t.Errorf("err{%v} != missing field mapping", err)
+	}
+}
+
+func TestTagPriority(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{TagPriority: []string{"db", "json"}}
+		rows   = scantest.Query(t, []string{"full_name", "nick"}, []driver.Value{"John Doe", "john"})
+		record struct {
+			ID    int    `json:"-"`
+			Name  string `db:"full_name" json:"name"`
+			Email string `db:"-" json:"email"`
+			Nick  string `json:"nick"`
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if record.Name != "John Doe" || record.Nick != "john" {
+		t.Errorf("record %+v != {Name:John Doe Nick:john}", record)
+	}
+
+	for _, column := range []string{"id", "email"} {
+		rows = scantest.Query(t, []string{column}, []driver.Value{"x"})
+		err = sc.Scan(context.Background(), rows, &record)
+
+		if err == nil || !strings.Contains(err.Error(), "missing field mapping") {
+			t.Errorf("column %s: err{%v} != missing field mapping", column, err)
+		}
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	var (
+		sc   sqlz.Scanner
+		rows = scantest.Query(t, []string{"pos_x", "pos_y", "name"}, []driver.Value{int64(3), int64(4), "p"})
+		// image.Point stands in for a type that can't be tagged.
+		record struct {
+			image.Point
+			Name string `db:"label"`
+		}
+	)
+	sc.RegisterType(reflect.TypeOf(image.Point{}), map[string]string{"X": "pos_x", "Y": "pos_y"})
+	sc.RegisterType(reflect.TypeOf(record), map[string]string{"Name": "name"})
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if record.X != 3 || record.Y != 4 || record.Name != "p" {
+		t.Errorf("record %v != {(3,4) p}", record)
+	}
+}
+
+func TestScanNested(t *testing.T) {
+	type item struct {
+		OrderID int `db:"order_id"`
+		Name    string
+	}
+	type order struct {
+		ID    int
+		Items []item `db:",nested=id:order_id"`
+	}
+	var (
+		parentRows = scantest.Query(t, []string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)}, []driver.Value{int64(3)})
+		childRows  = scantest.Query(t, []string{"order_id", "name"},
+			[]driver.Value{int64(1), "a"},
+			[]driver.Value{int64(3), "b"},
+			[]driver.Value{int64(1), "c"},
+		)
+		orders     []*order
+		parentKeys []any
+	)
+
+	err := sqlz.Default().ScanNested(context.Background(), parentRows, &orders, func(keys []any) (sqlz.Rows, error) {
+		parentKeys = keys
+		return childRows, nil
+	})
+
+	if err != nil {
+		t.Fatal("ScanNested(...):", err)
+	}
+	if want := []any{1, 2, 3}; !reflect.DeepEqual(parentKeys, want) {
+		t.Errorf("parentKeys %v != %v", parentKeys, want)
+	}
+	want := []*order{
+		{1, []item{{1, "a"}, {1, "c"}}},
+		{2, nil},
+		{3, []item{{3, "b"}}},
+	}
+	if !reflect.DeepEqual(orders, want) {
+		t.Errorf("orders %v != %v", orders, want)
+	}
+}
+
+func TestScanNestedKeys(t *testing.T) {
+	type item struct {
+		OrderID *int `db:"order_id"`
+		Name    string
+	}
+	type order struct {
+		ID    *int
+		Items []item `db:",nested=id:order_id"`
+	}
+	var (
+		parentRows = scantest.Query(t, []string{"id"}, []driver.Value{int64(1)}, []driver.Value{nil})
+		childRows  = scantest.Query(t, []string{"order_id", "name"}, []driver.Value{int64(1), "a"})
+		orders     []order
+		parentKeys []any
+	)
+
+	err := sqlz.Default().ScanNested(context.Background(), parentRows, &orders, func(keys []any) (sqlz.Rows, error) {
+		parentKeys = keys
+		return childRows, nil
+	})
+
+	if err != nil {
+		t.Fatal("ScanNested(...):", err)
+	}
+	if want := []any{1}; !reflect.DeepEqual(parentKeys, want) {
+		t.Errorf("parentKeys %v != %v", parentKeys, want)
+	}
+	if len(orders) != 2 || len(orders[0].Items) != 1 || orders[0].Items[0].Name != "a" || orders[1].Items != nil {
+		t.Errorf("orders %v != [{1 [{1 a}]} {<nil> []}]", orders)
+	}
+
+	type blob struct {
+		Key  []byte
+		Name string
+	}
+	type bucket struct {
+		Key   []byte
+		Blobs []blob `db:",nested=key:key"`
+	}
+	var buckets []bucket
+	parentRows = scantest.Query(t, []string{"key"}, []driver.Value{[]byte("k")})
+	childRows = scantest.Query(t, []string{"key", "name"}, []driver.Value{[]byte("k"), "a"}, []driver.Value{[]byte("k"), "b"})
+
+	err = sqlz.Default().ScanNested(context.Background(), parentRows, &buckets, func([]any) (sqlz.Rows, error) {
+		return childRows, nil
+	})
+
+	if err != nil {
+		t.Fatal("ScanNested(...):", err)
+	}
+	if len(buckets) != 1 || len(buckets[0].Blobs) != 2 {
+		t.Errorf("buckets %v != [{k [{k a} {k b}]}]", buckets)
+	}
+
+	type other struct {
+		OrderID string `db:"order_id"`
+	}
+	var mismatched []struct {
+		ID     int
+		Others []other `db:",nested=id:order_id"`
+	}
+	called := false
+
+	err = sqlz.Default().ScanNested(context.Background(), scantest.Query(t, []string{"id"}, []driver.Value{int64(1)}), &mismatched, func([]any) (sqlz.Rows, error) {
+		called = true
+		return nil, nil
+	})
+
+	if err == nil || err.Error() != "sqlz: child key of type string doesn't match parent key of type int" || called {
+		t.Errorf("err{%v} != `sqlz: child key of type string ...` or child was called", err)
+	}
+}
+
+func TestNullAsZero(t *testing.T) {
+	type record struct {
+		Name      string
+		Age       int
+		CreatedAt time.Time `db:"created_at"`
+		IsAdmin   bool      `db:"is_admin"`
+		Nick      *string
+		Null      sql.NullString
+	}
+	var (
+		sc      = sqlz.Scanner{NullAsZero: true}
+		columns = []string{"name", "age", "created_at", "is_admin", "nick", "null"}
+		ts      = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+		rows    = scantest.Query(t, columns,
+			[]driver.Value{"John", int64(42), ts, []byte{1}, "j", "x"},
+			[]driver.Value{nil, nil, nil, nil, nil, nil},
+		)
+		records []record
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	nick := "j"
+	want := []record{
+		{"John", 42, ts, true, &nick, sql.NullString{String: "x", Valid: true}},
+		{},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, columns, []driver.Value{nil, nil, nil, nil, nil, nil}), &r)
+
+	if err == nil {
+		t.Error("expected error without NullAsZero")
+	}
+}
+
+// labels stands in for a map type that's decoded from text.
+type labels map[string]string
+
+func TestNullAsZeroMap(t *testing.T) {
+	var (
+		sc   = sqlz.Scanner{NullAsZero: true}
+		rows = scantest.Query(t, []string{"labels"},
+			[]driver.Value{"env=prod"},
+			[]driver.Value{nil},
+		)
+		records []struct {
+			Labels labels
+		}
+	)
+	sc.RegisterKind(reflect.TypeOf(labels{}), func(src any, dst reflect.Value) error {
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("invalid labels %v", src)
+		}
+		k, v, _ := strings.Cut(s, "=")
+		dst.Set(reflect.ValueOf(labels{k: v}))
+		return nil
+	})
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if len(records) != 2 || !reflect.DeepEqual(records[0].Labels, labels{"env": "prod"}) || records[1].Labels != nil {
+		t.Errorf("records %v != [{map[env:prod]} {map[]}]", records)
+	}
+}
+
+// geometry stands in for a type decoded from WKB.
+type geometry struct {
+	X, Y float64
+}
+
+func TestRegisterKind(t *testing.T) {
+	type record struct {
+		Location geometry
+		Area     *geometry
+	}
+	var (
+		sc   sqlz.Scanner
+		rows = scantest.Query(t, []string{"location", "area"},
+			[]driver.Value{[]byte{1, 2}, nil},
+			[]driver.Value{[]byte{3, 4}, []byte{5, 6}},
+		)
+		records []record
+	)
+	sc.RegisterKind(reflect.TypeOf(geometry{}), func(src any, dst reflect.Value) error {
+		b, ok := src.([]byte)
+		if !ok || len(b) != 2 {
+			return fmt.Errorf("invalid geometry %v", src)
+		}
+		dst.Set(reflect.ValueOf(geometry{float64(b[0]), float64(b[1])}))
+		return nil
+	})
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	want := []record{
+		{geometry{1, 2}, nil},
+		{geometry{3, 4}, &geometry{5, 6}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+}
+
+// celsius implements sqlz.Decoder.
+type celsius float64
+
+func (c *celsius) DecodeSQL(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("invalid temperature %v", src)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "C"), 64)
+	*c = celsius(f)
+	return err
+}
+
+func TestScanDecoder(t *testing.T) {
+	type record struct {
+		Min celsius
+		Max *celsius
+	}
+	var (
+		rows = scantest.Query(t, []string{"min", "max"},
+			[]driver.Value{"-2.5C", nil},
+			[]driver.Value{"10C", "21.5C"},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	high := celsius(21.5)
+	if want := []record{{-2.5, nil}, {10, &high}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+}
+
+func TestScanChanBatched(t *testing.T) {
+	var (
+		ch      = make(chan []*testStruct, 10)
+		batches [][]*testStruct
+	)
+
+	err := sqlz.ScanChanBatched(context.Background(), nil, scantest.NewRows(5), ch, 2)
+	close(ch)
+
+	if err != nil {
+		t.Fatal("sqlz.ScanChanBatched(...):", err)
+	}
+	for batch := range ch {
+		batches = append(batches, batch)
+	}
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[1]) != 2 || len(batches[2]) != 1 {
+		t.Fatalf("batches of %v != batches of 2, 2 and 1", batches)
+	}
+	if batches[0][0] == batches[0][1] || batches[2][0].ID != 1146 {
+		t.Errorf("batches[0] %v or batches[2] %v are not scanned properly", batches[0], batches[2])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ch = make(chan []*testStruct, 10)
+
+	err = sqlz.ScanChanBatched(ctx, nil, scantest.NewRows(5), ch, 2)
+
+	if err != context.Canceled || len(ch) != 0 {
+		t.Errorf("err{%v} != context.Canceled or %d batches were sent", err, len(ch))
+	}
+}
+
+func TestScanStrict(t *testing.T) {
+	type record struct {
+		ID   int
+		Name string
+	}
+	var (
+		columns = []string{"id", "name", "id"}
+		values  = []driver.Value{int64(1), "a", int64(2)}
+		r       record
+	)
+
+	err := sqlz.Scan(context.Background(), scantest.Query(t, columns, values), &r)
+
+	if err != nil || r.ID != 2 {
+		t.Errorf("r.ID %d != 2 or err{%v} != nil", r.ID, err)
+	}
+
+	sc := sqlz.Scanner{Strict: true}
+	err = sc.Scan(context.Background(), scantest.Query(t, columns, values), &r)
+
+	if want := `sqlz: columns "id" and "id" map to the same field id`; err == nil || err.Error() != want {
+		t.Errorf("err{%v} != %s", err, want)
+	}
+}
+
+type tenantKey struct{}
+
+func TestContextFieldResolver(t *testing.T) {
+	type record struct {
+		TenantID int `db:"tenant_id,ctx"`
+		Name     string
+	}
+	var (
+		sc = sqlz.Scanner{
+			ContextFieldResolver: func(ctx context.Context, column string) (any, bool) {
+				v, ok := ctx.Value(tenantKey{}).(int64)
+				return v, ok && column == "tenant_id"
+			},
+		}
+		ctx     = context.WithValue(context.Background(), tenantKey{}, int64(7))
+		records []record
+	)
+
+	err := sc.Scan(ctx, scantest.Query(t, []string{"name"}, []driver.Value{"a"}, []driver.Value{"b"}), &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if want := []record{{7, "a"}, {7, "b"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sc.Scan(ctx, scantest.Query(t, []string{"tenant_id", "name"}, []driver.Value{int64(3), "c"}), &r)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if r != (record{3, "c"}) {
+		t.Errorf("r %v != {3 c}", r)
+	}
+
+	var small struct {
+		TenantID int8 `db:"tenant_id,ctx"`
+		Name     string
+	}
+	for _, v := range []any{int64(300), 1.5} {
+		sc.ContextFieldResolver = func(context.Context, string) (any, bool) { return v, true }
+		err = sc.Scan(ctx, scantest.Query(t, []string{"name"}, []driver.Value{"d"}), &small)
+
+		if want := fmt.Sprintf("sqlz: value %v from the context doesn't fit in field tenant_id of type int8", v); err == nil || err.Error() != want {
+			t.Errorf("err{%v} != %s", err, want)
+		}
+	}
+
+	sc.ContextFieldResolver = func(context.Context, string) (any, bool) { return -1.0, true }
+	err = sc.Scan(ctx, scantest.Query(t, []string{"name"}, []driver.Value{"d"}), &small)
+
+	if err != nil || small.TenantID != -1 {
+		t.Errorf("small.TenantID %d != -1 or err{%v} != nil", small.TenantID, err)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	var records []testStruct
+
+	err := sqlz.Scan(context.Background(), sqlz.Limit(scantest.NewRows(10), 3), &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("len(records) %d != 3", len(records))
+	}
+}
+
+func TestScanURL(t *testing.T) {
+	type record struct {
+		Home    url.URL
+		Profile *url.URL
+	}
+	var (
+		rows = scantest.Query(t, []string{"home", "profile"},
+			[]driver.Value{"https://example.com/", nil},
+			[]driver.Value{[]byte("https://example.com/a"), "/users/1?tab=posts"},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if len(records) != 2 || records[0].Home.Host != "example.com" || records[0].Profile != nil ||
+		records[1].Home.Path != "/a" || records[1].Profile.Query().Get("tab") != "posts" {
+		t.Errorf("records %v are not scanned properly", records)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"home"}, []driver.Value{"http://[::1"}), &r)
+
+	if err == nil || !strings.Contains(err.Error(), "http://[::1") {
+		t.Errorf("err{%v} != invalid URL", err)
+	}
+}
+
+// fahrenheit is registered with sqlz.RegisterGlobalKind.
+type fahrenheit struct {
+	Degrees float64
+}
+
+func TestRegisterGlobalKind(t *testing.T) {
+	type record struct {
+		Temp fahrenheit
+	}
+	sqlz.RegisterGlobalKind(reflect.TypeOf(fahrenheit{}), func(src any, dst reflect.Value) error {
+		f, err := strconv.ParseFloat(src.(string), 64)
+		dst.Set(reflect.ValueOf(fahrenheit{f}))
+		return err
+	})
+	var (
+		sc      sqlz.Scanner
+		records []record
+	)
+
+	err := sc.Scan(context.Background(), scantest.Query(t, []string{"temp"}, []driver.Value{"98.6"}), &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if want := []record{{fahrenheit{98.6}}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	sc = sqlz.Scanner{}
+	sc.RegisterKind(reflect.TypeOf(fahrenheit{}), func(src any, dst reflect.Value) error {
+		dst.Set(reflect.ValueOf(fahrenheit{-1}))
+		return nil
+	})
+	err = sc.Scan(context.Background(), scantest.Query(t, []string{"temp"}, []driver.Value{"98.6"}), &records)
+
+	if err != nil || records[1].Temp.Degrees != -1 {
+		t.Errorf("records %v != local kind or err{%v} != nil", records, err)
+	}
+
+	var temps []*fahrenheit
+	err = sc.Scan(context.Background(), scantest.Query(t, []string{"temp"}, []driver.Value{"98.6"}, []driver.Value{nil}), &temps)
+
+	if err != nil || len(temps) != 2 || *temps[0] != (fahrenheit{-1}) || temps[1] != nil {
+		t.Errorf("temps %v != [{-1} <nil>] or err{%v} != nil", temps, err)
+	}
+}
+
+func TestScanPointerStruct(t *testing.T) {
+	type address struct {
+		Street string
+		City   *string
+	}
+	type record struct {
+		ID      int
+		Address *address `db:"address"`
+	}
+	var (
+		sc   sqlz.Scanner
+		rows = scantest.Query(t, []string{"id", "address_street", "address_city"},
+			[]driver.Value{int64(1), nil, nil},
+			[]driver.Value{int64(2), "Main St", "Springfield"},
+			[]driver.Value{int64(3), "Elm St", nil},
+		)
+		records []record
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	city := "Springfield"
+	want := []record{
+		{1, nil},
+		{2, &address{"Main St", &city}},
+		{3, &address{"Elm St", nil}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+	if records[1].Address == records[2].Address {
+		t.Error("records share an address")
+	}
+}
+
+func TestScanPointerStructOptions(t *testing.T) {
+	type event struct {
+		Name string
+		At   time.Time
+	}
+	type record struct {
+		Event *event `db:"event"`
+	}
+	var (
+		loc = time.FixedZone("UTC+2", 2*60*60)
+		sc  = sqlz.Scanner{
+			Location:       loc,
+			StringSanitize: func(b []byte) string { return strings.TrimSpace(string(b)) },
+			InternStrings:  true,
+		}
+		ts   = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+		rows = scantest.Query(t, []string{"event_name", "event_at"},
+			[]driver.Value{[]byte(" launch "), ts},
+			[]driver.Value{[]byte("launch"), ts},
+		)
+		records []record
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	for _, r := range records {
+		if r.Event.Name != "launch" || r.Event.At.Location() != loc || !r.Event.At.Equal(ts) {
+			t.Errorf("r.Event %v != {launch %v}", r.Event, ts.In(loc))
+		}
+	}
+	if unsafe.StringData(records[0].Event.Name) != unsafe.StringData(records[1].Event.Name) {
+		t.Error("event names aren't interned")
+	}
+}
+
+func TestScanChecked(t *testing.T) {
+	var (
+		sc     sqlz.Scanner
+		record testStruct
+		nilPtr *testStruct
+		n      int
+		badTag struct {
+			Tags []string `db:"tags,hstore"`
+		}
+		tests = []struct {
+			dest any
+			err  error
+		}{
+			{nil, sqlz.ErrDestNil},
+			{nilPtr, sqlz.ErrDestNil},
+			{record, sqlz.ErrDestNotPointer},
+			{[]testStruct{}, sqlz.ErrDestNotPointer},
+			{&n, sqlz.ErrDestElemNotStruct},
+			{make(chan int), sqlz.ErrDestElemNotStruct},
+			{&badTag, sqlz.ErrMisuse},
+		}
+	)
+	for _, tt := range tests {
+		err := sc.ScanChecked(context.Background(), scantest.NewRows(1), tt.dest)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("sc.ScanChecked(%T): err{%v} != %v", tt.dest, err, tt.err)
+		}
+	}
+
+	err := sc.ScanChecked(context.Background(), scantest.NewRows(1), &record)
+
+	if err != nil || record.ID != 1146 {
+		t.Errorf("record.ID %d != 1146 or err{%v} != nil", record.ID, err)
+	}
+}
+
+func TestScanByteArray(t *testing.T) {
+	type record struct {
+		ID [16]byte
+	}
+	var (
+		raw  = []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+		rows = scantest.Query(t, []string{"id"},
+			[]driver.Value{raw},
+			[]driver.Value{"123e4567-e89b-12d3-a456-426614174000"},
+			[]driver.Value{nil},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if want := []record{{[16]byte(raw)}, {[16]byte(raw)}, {}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"id"}, []driver.Value{raw[:15]}), &r)
+
+	if err == nil || !strings.Contains(err.Error(), "length or syntax mismatch") {
+		t.Errorf("err{%v} != length mismatch", err)
+	}
+}
+
+// warnLogger records the warnings it receives.
+type warnLogger struct {
+	warnings []string
+}
+
+func (l *warnLogger) Warn(msg string, args ...any) {
+	l.warnings = append(l.warnings, fmt.Sprintln(append([]any{msg}, args...)...))
+}
+
+func TestLogger(t *testing.T) {
+	type record struct {
+		ID int
+	}
+	var (
+		logger warnLogger
+		sc     = sqlz.Scanner{IgnoreUnknownColumns: true, Logger: &logger}
+		r      record
+	)
+
+	for i := 0; i < 2; i++ {
+		rows := scantest.Query(t, []string{"id", "legacy"}, []driver.Value{int64(1), "x"})
+		if err := sc.Scan(context.Background(), rows, &r); err != nil {
+			t.Fatal("sc.Scan(...):", err)
+		}
+	}
+
+	want := []string{"sqlz: ignoring unknown column column legacy type sqlz_test.record\n"}
+	if !reflect.DeepEqual(logger.warnings, want) {
+		t.Errorf("warnings %q != %q", logger.warnings, want)
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	type record struct {
+		ID    int
+		Name  string
+		Score float64
+	}
+	var (
+		sc      = sqlz.Scanner{Positional: true}
+		columns = []string{"?column?", "name", "score", "rank", "total"}
+		rows    = scantest.Query(t, columns,
+			[]driver.Value{int64(1), "a", 0.5, int64(1), int64(2)},
+			[]driver.Value{int64(2), "b", 0.25, int64(2), int64(2)},
+		)
+		records []record
+	)
+
+	err := sc.ScanPrefix(context.Background(), rows, &records, 3)
+
+	if err != nil {
+		t.Fatal("sc.ScanPrefix(...):", err)
+	}
+	if want := []record{{1, "a", 0.5}, {2, "b", 0.25}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	err = sc.ScanPrefix(context.Background(), scantest.Query(t, columns[:2]), &records, 3)
+
+	if want := "sqlz: cannot scan the first 3 columns of 2"; err == nil || err.Error() != want {
+		t.Errorf("err{%v} != %s", err, want)
+	}
+
+	sc.NoPanic = true
+	err = sc.ScanPrefix(context.Background(), scantest.Query(t, columns), &records, -1)
+
+	if want := "sqlz: misuse: k must not be negative"; !errors.Is(err, sqlz.ErrMisuse) || err.Error() != want {
+		t.Errorf("err{%v} != %s", err, want)
+	}
+}
+
+func TestScanFunc(t *testing.T) {
+	var (
+		ids      []int
+		errFull  = errors.New("full")
+		visitErr = func(r *testStruct) error {
+			ids = append(ids, r.ID)
+			if len(ids) == 2 {
+				return errFull
+			}
+			return nil
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), scantest.NewRows(3), func(r *testStruct) error {
+		ids = append(ids, r.ID)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if want := []int{1146, 1146, 1146}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids %v != %v", ids, want)
+	}
+
+	ids = nil
+	err = sqlz.Scan(context.Background(), scantest.NewRows(3), visitErr)
+
+	if err != errFull || len(ids) != 2 {
+		t.Errorf("err{%v} != errFull or len(ids) %d != 2", err, len(ids))
+	}
+
+	defer func() {
+		if r := recover(); r != "dest func must be of type func(*T) error, where T is a struct" {
+			t.Errorf("recover() %v != invalid dest func", r)
+		}
+	}()
+	sqlz.Scan(context.Background(), scantest.NewRows(1), func(r testStruct) {})
+}
+
+func TestDenyColumns(t *testing.T) {
+	type user struct {
+		ID           int
+		PasswordHash string `db:"password_hash"`
+	}
+	var (
+		columns = []string{"id", "password_hash"}
+		values  = []driver.Value{int64(1), "secret"}
+		u       = user{PasswordHash: "untouched"}
+	)
+
+	sc := sqlz.Scanner{DenyColumns: []string{"password_hash"}}
+	err := sc.Scan(context.Background(), scantest.Query(t, columns, values), &u)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if u != (user{1, "untouched"}) {
+		t.Errorf("u %v != {1 untouched}", u)
+	}
+
+	u = user{PasswordHash: "untouched"}
+	sc = sqlz.Scanner{AllowColumns: []string{"id"}}
+	err = sc.Scan(context.Background(), scantest.Query(t, columns, values), &u)
+
+	if err != nil || u != (user{1, "untouched"}) {
+		t.Errorf("u %v != {1 untouched} or err{%v} != nil", u, err)
+	}
+}
+
+func TestScannable(t *testing.T) {
+	type embedded struct{ ID int }
+	var (
+		sc    sqlz.Scanner
+		n     int
+		tests = []struct {
+			dest any
+			err  string
+		}{
+			{new(testStruct), ""},
+			{new([]*testStruct), ""},
+			{new([]int), ""},
+			{make(chan testStruct), ""},
+			{func(*testStruct) error { return nil }, ""},
+			{nil, "sqlz: dest is nil"},
+			{testStruct{}, "sqlz: dest must be a pointer, chan or func"},
+			{&n, "sqlz: dest must point to a struct or slice, be a chan of structs, or a func(*T) error"},
+			{new(struct{ *embedded }), "sqlz: misuse: cannot use embedded pointer in struct"},
+			{new(struct{ id int }), "sqlz: struct struct { id int } has no scannable fields"},
+		}
+	)
+	for _, tt := range tests {
+		err := sc.Scannable(tt.dest)
+
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("sc.Scannable(%T): err{%v} != %q", tt.dest, err, tt.err)
+		}
+	}
+}
+
+func TestDialect(t *testing.T) {
+	type record struct {
+		ID        int
+		CreatedAt int64 `db:"created_at" db_sqlite:"createdAt"`
+	}
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		var (
+			sc     = sqlz.Scanner{Dialect: dialect}
+			column = map[string]string{"postgres": "created_at", "sqlite": "createdAt"}[dialect]
+			rows   = scantest.Query(t, []string{"id", column}, []driver.Value{int64(1), int64(1696943661)})
+			r      record
+		)
+
+		err := sc.Scan(context.Background(), rows, &r)
+
+		if err != nil {
+			t.Fatalf("sc.Scan(...) with dialect %s: %v", dialect, err)
+		}
+		if r != (record{1, 1696943661}) {
+			t.Errorf("r %v != {1 1696943661} with dialect %s", r, dialect)
+		}
+	}
+}
+
+func TestIndexOptionsChange(t *testing.T) {
+	type Address struct {
+		City string
+	}
+	type record struct {
+		ID      int `db:"id" db_pg:"pg_id"`
+		Address `db:"address"`
+	}
+	var (
+		sc sqlz.Scanner
+		r  record
+	)
+
+	err := sc.Scan(context.Background(), scantest.Query(t, []string{"id", "addresscity"}, []driver.Value{int64(1), "Amsterdam"}), &r)
+
+	if err != nil || r != (record{1, Address{"Amsterdam"}}) {
+		t.Errorf("r %v != {1 {Amsterdam}} or err{%v} != nil", r, err)
+	}
+
+	sc.Dialect, sc.PrefixSeparator = "pg", "_"
+	err = sc.Scan(context.Background(), scantest.Query(t, []string{"pg_id", "address_city"}, []driver.Value{int64(2), "Utrecht"}), &r)
+
+	if err != nil || r != (record{2, Address{"Utrecht"}}) {
+		t.Errorf("r %v != {2 {Utrecht}} or err{%v} != nil", r, err)
+	}
+}
+
+func TestMustGet(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
+	var (
+		ctx     = context.Background()
+		columns = []string{"id", "name"}
+	)
+
+	record := sqlz.MustGet[user](ctx, scantest.Open(t, columns, []driver.Value{int64(1), "John"}), "")
+
+	if record != (user{1, "John"}) {
+		t.Errorf("record %v != {1 John}", record)
+	}
+
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("panic{%v} != sql.ErrNoRows", err)
+		}
+	}()
+
+	sqlz.MustGet[user](ctx, scantest.Open(t, columns), "")
+}
+
+func TestMustSelect(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = scantest.Open(t, []string{"name"}, []driver.Value{"John"}, []driver.Value{"Jane"})
+	)
+
+	names := sqlz.MustSelect[string](ctx, db, "")
+
+	if want := []string{"John", "Jane"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v != %v", names, want)
+	}
+}
+
+func TestPluck(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = scantest.Open(t, []string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+	)
+
+	ids, err := sqlz.Pluck[int](ctx, db, "")
+
+	if err != nil {
+		t.Fatal("sqlz.Pluck(...):", err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids %v != %v", ids, want)
+	}
+
+	db = scantest.Open(t, []string{"email"}, []driver.Value{"john@example.com"}, []driver.Value{nil})
+	emails, err := sqlz.Pluck[*string](ctx, db, "")
+
+	if err != nil {
+		t.Fatal("sqlz.Pluck(...):", err)
+	}
+	if len(emails) != 2 || *emails[0] != "john@example.com" || emails[1] != nil {
+		t.Errorf("emails %v != [john@example.com <nil>]", emails)
+	}
+
+	db = scantest.Open(t, []string{"id", "email"}, []driver.Value{int64(1), "john@example.com"})
+	_, err = sqlz.Pluck[string](ctx, db, "")
+
+	if err == nil || err.Error() != "sqlz: scanning into string requires exactly one column, not 2" {
+		t.Errorf("err{%v} != requires exactly one column", err)
+	}
+
+	_, err = sqlz.Pluck[testStruct](ctx, db, "")
+
+	if !errors.Is(err, sqlz.ErrMisuse) {
+		t.Errorf("err{%v} doesn't wrap sqlz.ErrMisuse", err)
+	}
+}
+
+func TestScanSQLNullFields(t *testing.T) {
+	type record struct {
+		Name      sql.NullString
+		DeletedAt sql.NullTime `db:"deleted_at"`
+	}
+	var (
+		ts   = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+		rows = scantest.Query(t, []string{"name", "deleted_at"},
+			[]driver.Value{"John", ts},
+			[]driver.Value{nil, nil},
+		)
+		records []record
+	)
+
+	mapping, err := sqlz.MappingFor(&records, []string{"name", "deleted_at"})
+
+	if err != nil {
+		t.Fatal("sqlz.MappingFor(...):", err)
+	}
+	if want := map[string][]int{"name": {0}, "deleted_at": {1}}; !reflect.DeepEqual(mapping, want) {
+		t.Errorf("mapping %v != %v", mapping, want)
+	}
+
+	err = sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	want := []record{
+		{sql.NullString{String: "John", Valid: true}, sql.NullTime{Time: ts, Valid: true}},
+		{},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+}
+
+func TestScanDuration(t *testing.T) {
+	var (
+		rows   = scantest.Query(t, []string{"timeout"}, []driver.Value{int64(1500 * time.Millisecond)})
+		record struct {
+			Timeout time.Duration
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if record.Timeout != 1500*time.Millisecond {
+		t.Errorf("record.Timeout{%s} != 1.5s", record.Timeout)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	type record struct {
+		CreatedAt time.Time  `db:"created_at"`
+		DeletedAt *time.Time `db:"deleted_at"`
+		UpdatedAt time.Time  `db:"updated_at,epoch"`
+	}
+	var (
+		loc  = time.FixedZone("UTC+2", 2*60*60)
+		sc   = sqlz.Scanner{Location: loc}
+		ts   = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+		rows = scantest.Query(t, []string{"created_at", "deleted_at", "updated_at"},
+			[]driver.Value{ts, ts, ts.Unix()},
+			[]driver.Value{ts, nil, ts.Unix()},
+		)
+		records []record
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	for i, r := range records {
+		if r.CreatedAt.Location() != loc || !r.CreatedAt.Equal(ts) || r.UpdatedAt.Location() != loc {
+			t.Errorf("records[%d] {%v %v} not in %v", i, r.CreatedAt, r.UpdatedAt, loc)
+		}
+	}
+	if r := records[0]; r.DeletedAt == nil || r.DeletedAt.Location() != loc {
+		t.Errorf("records[0].DeletedAt{%v} not in %v", r.DeletedAt, loc)
+	}
+	if r := records[1]; r.DeletedAt != nil {
+		t.Errorf("records[1].DeletedAt{%v} != nil", r.DeletedAt)
+	}
+}
+
+func TestStringSanitize(t *testing.T) {
+	var (
+		sc = sqlz.Scanner{
+			StringSanitize: func(b []byte) string {
+				return strings.ToValidUTF8(string(bytes.TrimPrefix(b, []byte("\uFEFF"))), "?")
+			},
+		}
+		rows = scantest.Query(t, []string{"id", "username", "email"},
+			[]driver.Value{int64(1), []byte("\uFEFFjohn_doe"), []byte("john\xff@example.com")},
+		)
+		record testStructBase
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if record.Username != "john_doe" || record.Email != "john?@example.com" {
+		t.Errorf("record %+v != {Username:john_doe Email:john?@example.com}", record)
+	}
+}
+
+func TestScanEpoch(t *testing.T) {
+	var (
+		rows = scantest.Query(t, []string{"created_at", "updated_at", "deleted_at"},
+			[]driver.Value{int64(1696943661), int64(1696943661123), nil},
+			[]driver.Value{"1696943661", []byte("-1500"), int64(1696943661000)},
+		)
+		records []struct {
+			CreatedAt time.Time  `db:"created_at,epoch"`
+			UpdatedAt time.Time  `db:"updated_at,epoch_ms"`
+			DeletedAt *time.Time `db:"deleted_at,epoch_ms"`
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records){%d} != 2", len(records))
+	}
+	var (
+		ts   = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+		tsMs = ts.Add(123 * time.Millisecond)
+	)
+	if r := records[0]; !r.CreatedAt.Equal(ts) || !r.UpdatedAt.Equal(tsMs) || r.DeletedAt != nil {
+		t.Errorf("records[0] %v != {%v %v <nil>}", r, ts, tsMs)
+	}
+	if r := records[1]; !r.CreatedAt.Equal(ts) || !r.UpdatedAt.Equal(time.UnixMilli(-1500)) || r.DeletedAt == nil || !r.DeletedAt.Equal(ts) {
+		t.Errorf("records[1] %v != {%v %v %v}", r, ts, time.UnixMilli(-1500), ts)
+	}
+}
+
+func TestScanToEpoch(t *testing.T) {
+	var (
+		ts   = time.Date(2023, 10, 10, 13, 14, 21, 123456789, time.UTC)
+		rows = scantest.Query(t, []string{"created_at", "updated_at", "deleted_at"},
+			[]driver.Value{ts, ts, nil},
+		)
+		record struct {
+			CreatedAt int64  `db:"created_at,epoch"`
+			UpdatedAt int64  `db:"updated_at,epoch_ms"`
+			DeletedAt *int64 `db:"deleted_at,epoch"`
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if record.CreatedAt != 1696943661 || record.UpdatedAt != 1696943661123 || record.DeletedAt != nil {
+		t.Errorf("record %v != {1696943661 1696943661123 <nil>}", record)
+	}
+}
+
+func TestScanInvalidEpoch(t *testing.T) {
+	var record struct {
+		CreatedAt string `db:"created_at,epoch"`
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	sqlz.Scan(context.Background(), scantest.Query(t, []string{"created_at"}), &record)
+}
+
+func TestScanBig(t *testing.T) {
+	type record struct {
+		Rat    big.Rat
+		Float  *big.Float
+		Int    big.Int
+		RatPtr *big.Rat `db:"rat_ptr"`
+	}
+	var (
+		rows = scantest.Query(t, []string{"rat", "float", "int", "rat_ptr"},
+			[]driver.Value{"3.14", []byte("3.14"), []byte("314"), nil},
+			[]driver.Value{nil, nil, nil, float64(0.5)},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records, sqlz.WithIgnoreUnknownColumns(true))
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records){%d} != 2", len(records))
+	}
+	r := records[0]
+	if r.Rat.Cmp(big.NewRat(314, 100)) != 0 || r.Float == nil || r.Float.String() != "3.14" || r.Int.Int64() != 314 || r.RatPtr != nil {
+		t.Errorf("records[0] {%v %v %v %v} != {157/50 3.14 314 <nil>}", &r.Rat, r.Float, &r.Int, r.RatPtr)
+	}
+	r = records[1]
+	if r.Rat.Sign() != 0 || r.Float != nil || r.Int.Sign() != 0 || r.RatPtr == nil || r.RatPtr.Cmp(big.NewRat(1, 2)) != 0 {
+		t.Errorf("records[1] {%v %v %v %v} != {0 <nil> 0 1/2}", &r.Rat, r.Float, &r.Int, r.RatPtr)
+	}
+
+	var ints []big.Int
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"n"}, []driver.Value{"3.14"}), &ints)
+
+	if err == nil || !strings.Contains(err.Error(), `converting "3.14" to big.Int`) {
+		t.Errorf("err{%v} != invalid syntax", err)
+	}
+}
+
+func TestScanBigIntPtr(t *testing.T) {
+	type record struct {
+		Balance *big.Int
+	}
+	var (
+		rows = scantest.Query(t, []string{"balance"},
+			[]driver.Value{[]byte("123456789012345678901234567890")},
+			[]driver.Value{int64(-42)},
+			[]driver.Value{nil},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	large, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if want := []record{{large}, {big.NewInt(-42)}, {nil}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+}
+
+func TestScanIP(t *testing.T) {
+	type record struct {
+		IP      net.IP
+		Addr    netip.Addr
+		AddrPtr *netip.Addr `db:"addr_ptr"`
+	}
+	var (
+		rows = scantest.Query(t, []string{"ip", "addr", "addr_ptr"},
+			[]driver.Value{"192.168.0.1", []byte("2001:db8::1"), "::1"},
+			[]driver.Value{[]byte("2001:db8::1"), "10.0.0.1", nil},
+			[]driver.Value{nil, nil, nil},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	loopback := netip.IPv6Loopback()
+	want := []record{
+		{net.ParseIP("192.168.0.1"), netip.MustParseAddr("2001:db8::1"), &loopback},
+		{net.ParseIP("2001:db8::1"), netip.MustParseAddr("10.0.0.1"), nil},
+		{nil, netip.Addr{}, nil},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var ips []net.IP
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"ip"}, []driver.Value{"localhost"}), &ips)
+
+	if err == nil || !strings.Contains(err.Error(), "invalid IP address") {
+		t.Errorf("err{%v} != invalid IP address", err)
+	}
+}
+
+func TestScanJSONField(t *testing.T) {
+	type address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+	type record struct {
+		ID      int
+		Address address        `db:"data,json"`
+		Tags    []string       `db:"tags,json"`
+		Meta    map[string]int `db:"meta,json"`
+	}
+	var (
+		rows = scantest.Query(t, []string{"id", "data", "tags", "meta"},
+			[]driver.Value{int64(1), []byte(`{"city":"Amsterdam","country":"NL"}`), `["a","b"]`, []byte(`{"n":1}`)},
+			[]driver.Value{int64(2), nil, nil, []byte(`{"m":2}`)},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	want := []record{
+		{1, address{"Amsterdam", "NL"}, []string{"a", "b"}, map[string]int{"n": 1}},
+		{2, address{}, nil, map[string]int{"m": 2}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"data"}, []driver.Value{"{"}), &r)
+
+	if err == nil || !strings.Contains(err.Error(), "sqlz: decoding JSON into sqlz_test.address") {
+		t.Errorf("err{%v} != decoding JSON", err)
+	}
+}
+
+func TestScanJSONMaps(t *testing.T) {
+	type record struct {
+		Counts map[string]int      `db:"counts,json"`
+		Groups map[string][]string `db:"groups,json"`
+		Names  map[int]string      `db:"names,json"`
+	}
+	var (
+		r    = record{Counts: map[string]int{"stale": 1}}
+		rows = scantest.Query(t, []string{"counts", "groups", "names"},
+			[]driver.Value{`{"a":1,"b":2}`, []byte(`{"admins":["ann","bob"],"guests":[]}`), `{"1":"one","2":"two"}`},
+		)
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &r)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	want := record{
+		Counts: map[string]int{"a": 1, "b": 2},
+		Groups: map[string][]string{"admins": {"ann", "bob"}, "guests": {}},
+		Names:  map[int]string{1: "one", 2: "two"},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("r %v != %v", r, want)
+	}
+
+	rows = scantest.Query(t, []string{"counts"}, []driver.Value{`{"a":"x"}`})
+	err = sqlz.Scan(context.Background(), rows, &r)
+
+	if err == nil || !strings.Contains(err.Error(), "sqlz: decoding JSON into map[string]int") {
+		t.Errorf("err{%v} != decoding JSON", err)
+	}
+}
+
+func TestScanHstore(t *testing.T) {
+	type record struct {
+		Attrs    map[string]string  `db:"attrs,hstore"`
+		AttrPtrs map[string]*string `db:"attr_ptrs,hstore"`
+	}
+	var (
+		hstore = `"a"=>"1", "b c"=>"say \"hi\"", "back\\slash"=>NULL, d=>"NULL"`
+		rows   = scantest.Query(t, []string{"attrs", "attr_ptrs"},
+			[]driver.Value{hstore, []byte(hstore)},
+			[]driver.Value{"", nil},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	var (
+		one, hi, null = "1", `say "hi"`, "NULL"
+		want          = []record{
+			{
+				map[string]string{"a": "1", "b c": `say "hi"`, `back\slash`: "", "d": "NULL"},
+				map[string]*string{"a": &one, "b c": &hi, `back\slash`: nil, "d": &null},
+			},
+			{map[string]string{}, nil},
+		}
+	)
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"attrs"}, []driver.Value{`"a"=>"1`}), &r)
+
+	if err == nil || !strings.Contains(err.Error(), "unterminated quoted string") {
+		t.Errorf("err{%v} != unterminated quoted string", err)
+	}
+}
+
+func TestScanPgArray(t *testing.T) {
+	type record struct {
+		Tags  []string  `db:"tags,pgarray"`
+		IDs   []int64   `db:"ids,pgarray"`
+		Notes []*string `db:"notes,pgarray"`
+	}
+	var (
+		rows = scantest.Query(t, []string{"tags", "ids", "notes"},
+			[]driver.Value{[]byte(`{a,b,"c,d"}`), "{1,2,3}", `{"say \"hi\"",NULL,"NULL"}`},
+			[]driver.Value{"{}", nil, "{}"},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	var (
+		hi, null = `say "hi"`, "NULL"
+		want     = []record{
+			{[]string{"a", "b", "c,d"}, []int64{1, 2, 3}, []*string{&hi, nil, &null}},
+			{[]string{}, nil, []*string{}},
+		}
+	)
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"ids"}, []driver.Value{"{1,x}"}), &r)
+
+	if err == nil || !strings.Contains(err.Error(), "element 1") {
+		t.Errorf("err{%v} != invalid element 1", err)
+	}
+}
+
+func TestScanColumnPosition(t *testing.T) {
+	type record struct {
+		ID    int    `db:"id"`
+		Total int    `db:"#1"`
+		Label string `db:"#2"`
+	}
+	var (
+		rows = scantest.Query(t, []string{"id", "sum", "?column?"},
+			[]driver.Value{int64(1), int64(10), "a"},
+			[]driver.Value{int64(2), int64(20), "b"},
+		)
+		records []record
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.Scan(...):", err)
+	}
+	if want := []record{{1, 10, "a"}, {2, 20, "b"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
+	}
+
+	var r record
+	err = sqlz.Scan(context.Background(), scantest.Query(t, []string{"id", "sum"}, []driver.Value{int64(1), int64(10)}), &r)
+
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("err{%v} != out of range", err)
+	}
+}
+
+func TestCheckTypes(t *testing.T) {
+	var (
+		sc   sqlz.Scanner
+		rows = scantest.Query(t,
+			[]string{"id", "name", "age", "nickname", "created_at"},
+			[]driver.Value{int64(1), "John", "42", nil, time.Now()},
+		)
+		record struct {
+			ID        int
+			Name      []byte
+			Age       int
+			Nickname  sql.NullString
+			CreatedAt *time.Time `db:"created_at"`
+		}
+	)
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal("rows.ColumnTypes():", err)
+	}
+
+	err = sc.CheckTypes(&record, cts)
+
+	if err == nil || err.Error() != `sqlz: column "age" of type string is incompatible with field of type int` {
+		t.Errorf("err{%v} != `sqlz: column \"age\" ...`", err)
+	}
+
+	rows = scantest.Query(t,
+		[]string{"ID", "Name", "password"},
+		[]driver.Value{int64(1), "John", "secret"},
+	)
+	cts, err = rows.ColumnTypes()
+	if err != nil {
+		t.Fatal("rows.ColumnTypes():", err)
+	}
+	sc = sqlz.Scanner{NormalizeColumn: strings.ToLower, DenyColumns: []string{"password"}}
+
+	err = sc.CheckTypes(&record, cts)
+
+	if err != nil {
+		t.Error("sc.CheckTypes(...):", err)
+	}
+}
+
+func TestCheckTypesConverters(t *testing.T) {
+	var record struct {
+		Flag   bool
+		Bit    bool
+		Amount big.Int
+		Ratio  *big.Rat
+		IP     net.IP
+		Addr   netip.Addr
+		Home   url.URL
+		Temp   celsius
+		Max    *celsius
+		UUID   [16]byte
+	}
+	rows := scantest.Query(t,
+		[]string{"flag", "bit", "amount", "ratio", "ip", "addr", "home", "temp", "max", "uuid"},
+		[]driver.Value{int64(1), []byte{1}, "42", "1/3", "192.0.2.1", []byte("192.0.2.1"), "https://example.com/", "21C", int64(30), make([]byte, 16)},
+	)
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal("rows.ColumnTypes():", err)
+	}
+
+	err = sqlz.Default().CheckTypes(&record, cts)
+
+	if err != nil {
+		t.Error("sqlz.Default().CheckTypes(...):", err)
+	}
+}
+
+func TestScanNoScannableFields(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
+		rows   = scantest.NewRows(1)
+		record struct {
+			id       int
+			Password []byte `db:"-"`
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err == nil || !strings.HasSuffix(err.Error(), "has no scannable fields") {
+		t.Errorf("err{%v} != `sqlz: struct ... has no scannable fields`", err)
+	}
+}
+
+func TestScanWithIgnoreUnknownColumns(t *testing.T) {
+	var (
+		rows   = scantest.NewRows(1)
+		record testStructBase
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record, sqlz.WithIgnoreUnknownColumns(true))
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if !reflect.DeepEqual(record, fixedTestStruct.testStructBase) {
+		t.Errorf("record %v != fixedTestStruct.testStructBase", record)
+	}
+}
+
+func TestMappingFor(t *testing.T) {
+	mapping, err := sqlz.MappingFor(&[]testStruct{}, []string{"created_at", "display_name", "id"})
+
+	if err != nil {
+		t.Error("sqlz.MappingFor(...):", err)
+	}
+	want := map[string][]int{
+		"created_at":   {1},
+		"display_name": {0, 2},
+		"id":           {0, 0},
+	}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("mapping %v != %v", mapping, want)
+	}
+
+	_, err = sqlz.MappingFor(testStruct{}, []string{"id", "password"})
+
+	if err == nil || err.Error() != `sqlz: missing field mapping for column "password"` {
+		t.Errorf("err{%v} != `sqlz: missing field mapping ...`", err)
+	}
+
+	sc := sqlz.Scanner{DenyColumns: []string{"password"}}
+	mapping, err = sc.MappingFor(testStruct{}, []string{"id", "password"})
+
+	if err != nil {
+		t.Error("sc.MappingFor(...):", err)
+	}
+	want = map[string][]int{"id": {0, 0}}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("mapping %v != %v", mapping, want)
+	}
+
+	sc = sqlz.Scanner{Strict: true}
+	_, err = sc.MappingFor(testStruct{}, []string{"id", "id"})
+
+	if err == nil || err.Error() != `sqlz: columns "id" and "id" map to the same field id` {
+		t.Errorf("err{%v} != `sqlz: columns ... map to the same field id`", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	type level2 struct {
+		Name string
+	}
+	type level1 struct {
+		level2
+	}
+	type record struct {
+		level1
+	}
+	var (
+		sc = sqlz.Scanner{MaxDepth: 1}
+		r  record
+	)
+
+	func() {
+		defer func() {
+			r := recover()
+			if msg, _ := r.(string); !strings.HasSuffix(msg, "struct field sqlz_test.record.level1.level2 (index [0 0]) exceeds the maximum depth of 1") {
+				t.Errorf("panic{%v} != struct field ...record.level1.level2 (index [0 0]) exceeds the maximum depth of 1", r)
+			}
+		}()
+		sc.Scan(context.Background(), scantest.Query(t, []string{"name"}), &r)
+	}()
+
+	// A self-referencing pointer is only traversed once, so it doesn't recurse endlessly.
+	type node struct {
+		ID   int
+		Next *node `db:"next"`
+	}
+	var n node
+
+	err := sqlz.Scan(context.Background(), scantest.Query(t, []string{"id", "next_id"}, []driver.Value{int64(1), int64(2)}), &n)
+
+	if err != nil || n.ID != 1 || n.Next == nil || n.Next.ID != 2 || n.Next.Next != nil {
+		t.Errorf("n %v != {1 {2 <nil>}} or err{%v} != nil", n, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var (
+		sc      sqlz.Scanner
+		current = fixedTestStruct
+		rows    = scantest.NewRows(1)
+	)
+	current.Email = "old@example.com"
+	current.Age = 41
+
+	changed, err := sc.Diff(&current, rows)
+
+	if err != nil {
+		t.Fatal("sc.Diff(...):", err)
+	}
+	if want := []string{"email", "age"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed %v != %v", changed, want)
+	}
+
+	sc = sqlz.Scanner{DenyColumns: []string{"email"}}
+	changed, err = sc.Diff(&current, scantest.NewRows(1))
+
+	if err != nil {
+		t.Fatal("sc.Diff(...):", err)
+	}
+	if want := []string{"age"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed %v != %v", changed, want)
+	}
+
+	changed, err = sc.Diff(account{1, 50}, scantest.Query(t, []string{"id", "balance"}, []driver.Value{int64(1), int64(100)}))
+
+	if err != nil || changed != nil {
+		t.Errorf("changed %v != nil or err{%v} != nil", changed, err)
+	}
+}
+
+func TestNoPanic(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{NoPanic: true}
+		record struct {
+			*testStructBase
+		}
+		n int
+	)
+
+	tests := []struct {
+		dest any
+		msg  string
+	}{
+		{record, "dest must be a pointer, chan or func"},
+		{&n, "dest must point to a struct or slice"},
+		{make(chan int), "dest chan of non-struct elements"},
+		{&record, "cannot use embedded pointer in struct"},
+	}
+	for _, tt := range tests {
+		err := sc.Scan(context.Background(), scantest.NewRows(1), tt.dest)
+
+		if !errors.Is(err, sqlz.ErrMisuse) || err.Error() != "sqlz: misuse: "+tt.msg {
+			t.Errorf("err{%v} != misuse: %s", err, tt.msg)
+		}
+	}
+}
+
+func TestEmbeddedPointerField(t *testing.T) {
+	var (
+		rows   = scantest.NewRows(1)
+		record struct {
+			*testStruct
+		}
+	)
+
+	defer func() {
+		msg := recover().(string)
+		if msg != "cannot use embedded pointer in struct" {
+			t.Error("expected embedded pointer panic")
+		}
+	}()
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+}
+
+func TestPointerField(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
+		rows   = scantest.NewRows(1)
+		record struct {
+			Row *testStruct
+		}
+	)
+
+	// The Row field of record should be silently ignored.
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+}
+
+func TestScanSmallStruct(t *testing.T) {
+	var (
+		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
+		rows   = scantest.NewRows(1)
+		record struct {
+			Age int
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if record.Age != 42 {
+		t.Errorf("record.Age{%d} != 42", record.Age)
+	}
+}
+
+type Age int
+
+func TestEmbeddedScalarField(t *testing.T) {
+	var (
+		rows   = scantest.Query(t, []string{"email", "age"}, []driver.Value{"john@example.com", int64(42)})
+		record struct {
+			Age
+			Email string
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if record.Age != 42 {
+		t.Errorf("record.Age{%d} != 42", record.Age)
+	}
+}
+
+func TestEmbeddedInterfaceField(t *testing.T) {
+	var (
+		rows   = scantest.NewRows(1)
+		record struct {
+			testStruct
+			sqlz.Rows // any interface will do
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if !reflect.DeepEqual(record.testStruct, fixedTestStruct) {
+		t.Errorf("record %v != fixedTestStruct", record)
+	}
+}
+
+func TestEmbeddedInterfaceFieldNotMapped(t *testing.T) {
+	var (
+		rows   = scantest.Query(t, []string{"id", "stringer"}, []driver.Value{int64(1), "x"})
+		record struct {
+			ID int
+			fmt.Stringer
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &record)
+
+	if err == nil || !strings.Contains(err.Error(), `missing field mapping for column "stringer"`) {
+		t.Errorf("err{%v} != missing field mapping", err)
+	}
+}
+
+func TestScanSlice(t *testing.T) {
+	var (
+		rows    = scantest.NewRows(4)
+		records []*testStruct
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if len(records) != 4 {
+		t.Errorf("len(records){%d} != 4", len(records))
+	}
+	for i, rec := range records {
+		if !reflect.DeepEqual(*rec, fixedTestStruct) {
+			t.Errorf("record[%d] %v != fixedTestStruct", i, *rec)
+		}
+	}
+}
+
+// cancelRows cancels a context after n rows.
+type cancelRows struct {
+	*scantest.Rows
+	n      int
+	cancel context.CancelFunc
+}
+
+func (r *cancelRows) Next() bool {
+	if r.n == 0 {
+		r.cancel()
+	}
+	r.n--
+	return r.Rows.Next()
+}
+
+func TestScanSliceCanceled(t *testing.T) {
+	for _, partial := range []bool{false, true} {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			sc          = sqlz.Scanner{PartialOnCancel: partial}
+			rows        = &cancelRows{scantest.NewRows(5), 2, cancel}
+			records     = []*testStruct{&fixedTestStruct}
+		)
+
+		err := sc.Scan(ctx, rows, &records)
+
+		if err != context.Canceled {
+			t.Errorf("err{%v} != context.Canceled", err)
+		}
+		wantLen := 1
+		if partial {
+			wantLen = 3
+		}
+		if len(records) != wantLen {
+			t.Errorf("PartialOnCancel{%t}: len(records){%d} != %d", partial, len(records), wantLen)
+		}
+	}
+}
+
+func TestScanAppend(t *testing.T) {
+	var (
+		sc       sqlz.Scanner
+		rows     = scantest.NewRows(7)
+		records  []testStruct
+		progress []int
+	)
+
+	err := sc.ScanAppend(context.Background(), rows, &records, 3, func(n int) {
+		progress = append(progress, n)
+	})
+
+	if err != nil {
+		t.Fatal("sc.ScanAppend(...):", err)
+	}
+	if len(records) != 7 {
+		t.Errorf("len(records){%d} != 7", len(records))
+	}
+	if want := []int{3, 6}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress %v != %v", progress, want)
+	}
+
+	sc.NoPanic = true
+	err = sc.ScanAppend(context.Background(), scantest.NewRows(1), &records, 0, nil)
+
+	if !errors.Is(err, sqlz.ErrMisuse) {
+		t.Errorf("err{%v} != sqlz.ErrMisuse", err)
+	}
+}
+
+func TestScanInternStrings(t *testing.T) {
+	var (
+		sc   = sqlz.Scanner{InternStrings: true}
+		rows = scantest.Query(t, []string{"id", "status"},
+			[]driver.Value{int64(1), []byte("active")},
+			[]driver.Value{int64(2), []byte("active")},
+		)
+		records []struct {
+			ID     int
+			Status string
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records){%d} != 2", len(records))
+	}
+	if records[1].Status != "active" || unsafe.StringData(records[0].Status) != unsafe.StringData(records[1].Status) {
+		t.Error("records[0].Status and records[1].Status are not interned")
+	}
+}
+
+func TestScanInternStringsNullAsZero(t *testing.T) {
+	var (
+		sc   = sqlz.Scanner{InternStrings: true, NullAsZero: true}
+		rows = scantest.Query(t, []string{"id", "status"},
+			[]driver.Value{int64(1), []byte("active")},
+			[]driver.Value{int64(2), nil},
+			[]driver.Value{int64(3), []byte("active")},
+		)
+		records []struct {
+			ID     int
+			Status string
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("len(records){%d} != 3", len(records))
+	}
+	if records[1].Status != "" {
+		t.Errorf("records[1].Status %q != \"\"", records[1].Status)
+	}
+	if records[2].Status != "active" || unsafe.StringData(records[0].Status) != unsafe.StringData(records[2].Status) {
+		t.Error("records[0].Status and records[2].Status are not interned")
+	}
+}
+
+func TestScanInternStringsSanitize(t *testing.T) {
+	var (
+		sc = sqlz.Scanner{
+			InternStrings:  true,
+			StringSanitize: func(b []byte) string { return strings.TrimSpace(string(b)) },
+		}
+		rows = scantest.Query(t, []string{"id", "status"},
+			[]driver.Value{int64(1), []byte("active ")},
+			[]driver.Value{int64(2), []byte(" active")},
+		)
+		records []struct {
+			ID     int
+			Status string
+		}
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records){%d} != 2", len(records))
+	}
+	if records[1].Status != "active" || unsafe.StringData(records[0].Status) != unsafe.StringData(records[1].Status) {
+		t.Error("records[0].Status and records[1].Status are not interned")
+	}
+}
+
+func TestScanScalarSlice(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		values = [][]driver.Value{{int64(1)}, {nil}, {int64(3)}}
+		ids    []int
+		names  []string
+		ptrs   []*int
+		nulls  []sql.NullInt64
+	)
+
+	if err := sqlz.Scan(ctx, scantest.Query(t, []string{"id"}, values[0], values[2]), &ids); err != nil {
+		t.Error("sqlz.Scan(..., &ids):", err)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids %v != %v", ids, want)
+	}
+	if err := sqlz.Scan(ctx, scantest.Query(t, []string{"name"}, []driver.Value{"John"}, []driver.Value{[]byte("Jane")}), &names); err != nil {
+		t.Error("sqlz.Scan(..., &names):", err)
+	}
+	if want := []string{"John", "Jane"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v != %v", names, want)
+	}
+	if err := sqlz.Scan(ctx, scantest.Query(t, []string{"id"}, values...), &ptrs); err != nil {
+		t.Error("sqlz.Scan(..., &ptrs):", err)
+	}
+	if len(ptrs) != 3 || *ptrs[0] != 1 || ptrs[1] != nil || *ptrs[2] != 3 {
+		t.Errorf("ptrs %v != [1 <nil> 3]", ptrs)
+	}
+	if err := sqlz.Scan(ctx, scantest.Query(t, []string{"id"}, values...), &nulls); err != nil {
+		t.Error("sqlz.Scan(..., &nulls):", err)
+	}
+	if want := []sql.NullInt64{{Int64: 1, Valid: true}, {}, {Int64: 3, Valid: true}}; !reflect.DeepEqual(nulls, want) {
+		t.Errorf("nulls %v != %v", nulls, want)
+	}
+}
+
+func TestScanScalarSliceColumns(t *testing.T) {
+	var (
+		rows = scantest.NewRows(1)
+		ids  []int
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &ids)
+
+	if err == nil || err.Error() != "sqlz: scanning into int requires exactly one column, not 7" {
+		t.Errorf("err{%v} != `sqlz: scanning into int requires ...`", err)
+	}
+}
+
+func TestScanRawBytes(t *testing.T) {
+	var (
+		rows = scantest.Query(t, []string{"id", "data"},
+			[]driver.Value{int64(1), []byte("first")},
+			[]driver.Value{int64(2), []byte("second")},
+		)
+		records []struct {
+			ID   int
+			Data sql.RawBytes
+		}
+	)
+
+	err := sqlz.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Error("sqlz.Scan(...):", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records){%d} != 2", len(records))
+	}
+	if string(records[0].Data) != "first" || string(records[1].Data) != "second" {
+		t.Errorf("records[*].Data {%s, %s} != {first, second}", records[0].Data, records[1].Data)
+	}
+}
+
+func TestScanToJSON(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	var (
+		rows = scantest.Query(t, []string{"id", "name"},
+			[]driver.Value{int64(1), "John"},
+			[]driver.Value{int64(2), "Jane"},
+		)
+		buf strings.Builder
+	)
+
+	err := sqlz.ScanToJSON[user](context.Background(), nil, rows, &buf)
+
+	if err != nil {
+		t.Error("sqlz.ScanToJSON(...):", err)
+	}
+	if want := `[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]`; buf.String() != want {
+		t.Errorf("buf %s != %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	err = sqlz.ScanToJSON[user](context.Background(), nil, scantest.Query(t, []string{"id", "name"}), &buf)
+
+	if err != nil {
+		t.Error("sqlz.ScanToJSON(...):", err)
+	}
+	if buf.String() != "[]" {
+		t.Errorf("buf %s != []", buf.String())
+	}
+}
+
+// failRows fails the n-th call to Scan with err.
+type failRows struct {
+	*scantest.Rows
+	n   int
+	err error
+}
+
+func (r *failRows) Scan(dest ...any) error {
+	if r.n--; r.n == 0 {
+		return r.err
+	}
+	return r.Rows.Scan(dest...)
+}
+
+// closeRows counts the calls to Close.
+type closeRows struct {
+	sqlz.Rows
+	closed int
+	err    error
+}
+
+func (r *closeRows) Close() error {
+	r.closed++
+	return r.err
+}
+
+func TestScanClose(t *testing.T) {
+	var (
+		errBadData = errors.New("bad data")
+		errClose   = errors.New("close failed")
+		records    []testStruct
+	)
+
+	rows := &closeRows{Rows: scantest.NewRows(2)}
+	err := sqlz.ScanClose(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sqlz.ScanClose(...):", err)
+	}
+	if len(records) != 2 || rows.closed != 1 {
+		t.Errorf("len(records) %d != 2 or rows closed %d times", len(records), rows.closed)
+	}
+
+	rows = &closeRows{Rows: &failRows{scantest.NewRows(2), 1, errBadData}, err: errClose}
+	err = sqlz.ScanClose(context.Background(), rows, &records)
+
+	if !errors.Is(err, errBadData) || !errors.Is(err, errClose) {
+		t.Errorf("err{%v} doesn't wrap errBadData and errClose", err)
+	}
+	if rows.closed != 1 {
+		t.Errorf("rows closed %d times != 1", rows.closed)
+	}
+}
+
+func TestScanSliceRowError(t *testing.T) {
+	var (
+		errBadData = errors.New("bad data")
+		rows       = &failRows{scantest.NewRows(4), 3, errBadData}
+		records    []testStruct
 	)
 
-	err := sqlz.Scan(context.Background(), rows, &record)
+	err := sqlz.Scan(context.Background(), rows, &records)
 
-	if err.Error() != `sqlz: missing field mapping for column "created_at"` {
-		t.Errorf("err{%s} != `sqlz: missing field mapping ...`", err)
+	var rowErr *sqlz.RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 3 {
+		t.Fatalf("err{%v} != RowError at row 3", err)
+	}
+	if !errors.Is(err, errBadData) {
+		t.Errorf("err{%v} doesn't wrap errBadData", err)
+	}
+	if want := "sqlz: scan error at row 3: bad data"; err.Error() != want {
+		t.Errorf("err.Error(){%s} != %s", err, want)
 	}
 }
 
-func TestScanIgnoreUnknownColumns(t *testing.T) {
+func TestScanRetry(t *testing.T) {
 	var (
-		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
-		rows   = scantest.NewRows(1)
-		record testStructBase
+		sc           sqlz.Scanner
+		errTransient = errors.New("transient")
+		attempts     int
+		query        = func() (sqlz.Rows, error) {
+			attempts++
+			switch attempts {
+			case 1:
+				return nil, errTransient
+			case 2:
+				return &failRows{scantest.NewRows(4), 3, errTransient}, nil
+			}
+			return scantest.NewRows(4), nil
+		}
+		policy = sqlz.RetryPolicy{
+			MaxAttempts: 3,
+			Retryable: func(err error) bool {
+				return errors.Is(err, errTransient)
+			},
+		}
+		records []*testStruct
 	)
 
-	err := sc.Scan(context.Background(), rows, &record)
+	err := sc.ScanRetry(context.Background(), query, &records, policy)
 
 	if err != nil {
-		t.Error("sqlz.Scan(...):", err)
+		t.Error("sc.ScanRetry(...):", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts{%d} != 3", attempts)
+	}
+	if len(records) != 4 {
+		t.Errorf("len(records){%d} != 4", len(records))
+	}
+
+	attempts = 0
+	policy.MaxAttempts = 2
+
+	err = sc.ScanRetry(context.Background(), query, &records, policy)
+
+	if !errors.Is(err, errTransient) {
+		t.Errorf("err{%v} != errTransient", err)
+	}
+	if len(records) != 4 {
+		t.Errorf("len(records){%d} != 4", len(records))
 	}
 }
 
-func TestEmbeddedPointerField(t *testing.T) {
+func TestScanJSONRawMessage(t *testing.T) {
 	var (
-		rows   = scantest.NewRows(1)
-		record struct {
-			*testStruct
+		rows = scantest.Query(t, []string{"id", "doc"},
+			[]driver.Value{int64(1), []byte(`{"a":1}`)},
+			[]driver.Value{int64(2), `{"b":2}`},
+			[]driver.Value{int64(3), []byte(`{"c":3}`)},
+			[]driver.Value{int64(4), nil},
+		)
+		records []struct {
+			ID  int
+			Doc json.RawMessage
 		}
 	)
 
-	defer func() {
-		msg := recover().(string)
-		if msg != "cannot use embedded pointer in struct" {
-			t.Error("expected embedded pointer panic")
-		}
-	}()
-
-	err := sqlz.Scan(context.Background(), rows, &record)
+	err := sqlz.Scan(context.Background(), rows, &records)
 
 	if err != nil {
 		t.Error("sqlz.Scan(...):", err)
 	}
+	want := []string{`{"a":1}`, `{"b":2}`, `{"c":3}`, ""}
+	if len(records) != len(want) {
+		t.Fatalf("len(records){%d} != %d", len(records), len(want))
+	}
+	for i, rec := range records {
+		if string(rec.Doc) != want[i] {
+			t.Errorf("records[%d].Doc %s != %s", i, rec.Doc, want[i])
+		}
+	}
+	if records[3].Doc != nil {
+		t.Error("records[3].Doc from NULL != nil")
+	}
 }
 
-func TestPointerField(t *testing.T) {
+func TestScanMapInto(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
 	var (
-		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
-		rows   = scantest.NewRows(1)
-		record struct {
-			Row *testStruct
-		}
+		ctx     = context.Background()
+		columns = []string{"id", "name"}
+		users   = map[int]*user{}
 	)
 
-	// The Row field of record should be silently ignored.
-	err := sc.Scan(context.Background(), rows, &record)
+	err := sqlz.ScanMapInto(ctx, nil, scantest.Query(t, columns,
+		[]driver.Value{int64(1), "John"},
+		[]driver.Value{int64(2), "Jane"},
+	), users, "id")
 
 	if err != nil {
-		t.Error("sqlz.Scan(...):", err)
+		t.Fatal("sqlz.ScanMapInto(...):", err)
+	}
+
+	err = sqlz.ScanMapInto(ctx, nil, scantest.Query(t, columns,
+		[]driver.Value{int64(2), "Janet"},
+		[]driver.Value{int64(3), "Bob"},
+	), users, "id")
+
+	if err != nil {
+		t.Fatal("sqlz.ScanMapInto(...):", err)
+	}
+	want := map[int]*user{1: {1, "John"}, 2: {2, "Janet"}, 3: {3, "Bob"}}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("users %v != %v", users, want)
+	}
+
+	err = sqlz.ScanMapInto(ctx, nil, scantest.Query(t, columns), map[int]user(nil), "id")
+
+	if err == nil || err.Error() != "sqlz: ScanMapInto called with nil map" {
+		t.Errorf("err{%v} != nil map", err)
+	}
+
+	err = sqlz.ScanMapInto(ctx, nil, scantest.Query(t, columns), map[string]user{}, "id")
+
+	if err == nil || err.Error() != "sqlz: key field of type int doesn't match map key of type string" {
+		t.Errorf("err{%v} != key type mismatch", err)
 	}
 }
 
-func TestScanSmallStruct(t *testing.T) {
-	var (
-		sc     = sqlz.Scanner{IgnoreUnknownColumns: true}
-		rows   = scantest.NewRows(1)
-		record struct {
-			Age int
-		}
+func TestScanOrderedMap(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
+	rows := scantest.Query(t, []string{"id", "name"},
+		[]driver.Value{int64(3), "Bob"},
+		[]driver.Value{int64(1), "John"},
+		[]driver.Value{int64(3), "Bobby"},
+		[]driver.Value{int64(2), "Jane"},
 	)
 
-	err := sc.Scan(context.Background(), rows, &record)
+	keys, users, err := sqlz.ScanOrderedMap[int, user](context.Background(), nil, rows, "id")
 
 	if err != nil {
-		t.Error("sqlz.Scan(...):", err)
+		t.Fatal("sqlz.ScanOrderedMap(...):", err)
 	}
-	if record.Age != 42 {
-		t.Errorf("record.Age{%d} != 42", record.Age)
+	if want := []int{3, 1, 2}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys %v != %v", keys, want)
+	}
+	if want := map[int]user{1: {1, "John"}, 2: {2, "Jane"}, 3: {3, "Bobby"}}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users %v != %v", users, want)
 	}
 }
 
-func TestEmbeddedInterfaceField(t *testing.T) {
+func TestScanColumnar(t *testing.T) {
 	var (
-		rows   = scantest.NewRows(1)
-		record struct {
-			testStruct
-			sqlz.Rows // any interface will do
-		}
+		rows = scantest.Query(t, []string{"name", "score", "note"},
+			[]driver.Value{"John", int64(42), []byte("a")},
+			[]driver.Value{"Jane", float64(7), nil},
+		)
+		names  = []string{"Bob"}
+		scores []float64
+		notes  []*string
 	)
 
-	err := sqlz.Scan(context.Background(), rows, &record)
+	err := sqlz.ScanColumnar(context.Background(), rows, &names, &scores, &notes)
 
 	if err != nil {
-		t.Error("sqlz.Scan(...):", err)
+		t.Fatal("sqlz.ScanColumnar(...):", err)
 	}
-	if !reflect.DeepEqual(record.testStruct, fixedTestStruct) {
-		t.Errorf("record %v != fixedTestStruct", record)
+	if want := []string{"Bob", "John", "Jane"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v != %v", names, want)
+	}
+	if want := []float64{42, 7}; !reflect.DeepEqual(scores, want) {
+		t.Errorf("scores %v != %v", scores, want)
+	}
+	if len(notes) != 2 || notes[0] == nil || *notes[0] != "a" || notes[1] != nil {
+		t.Errorf("notes %v != [a <nil>]", notes)
 	}
 }
 
-func TestScanSlice(t *testing.T) {
+func TestScanColumnarMismatch(t *testing.T) {
 	var (
-		rows    = scantest.NewRows(4)
-		records []*testStruct
+		rows  = scantest.Query(t, []string{"name", "score"}, []driver.Value{"John", int64(42)})
+		names []string
+	)
+
+	err := sqlz.ScanColumnar(context.Background(), rows, &names)
+
+	if err == nil || !strings.Contains(err.Error(), "got 1 for 2 columns") {
+		t.Errorf("err{%v} != destination count mismatch", err)
+	}
+}
+
+func TestScanRownum(t *testing.T) {
+	type record struct {
+		N    int `db:",rownum"`
+		Name string
+	}
+	var (
+		rows    = scantest.Query(t, []string{"name"}, []driver.Value{"a"}, []driver.Value{"b"}, []driver.Value{"c"})
+		records []record
 	)
 
 	err := sqlz.Scan(context.Background(), rows, &records)
 
 	if err != nil {
-		t.Error("sqlz.Scan(...):", err)
-	}
-	if len(records) != 4 {
-		t.Errorf("len(records){%d} != 4", len(records))
+		t.Fatal("sqlz.Scan(...):", err)
 	}
-	for i, rec := range records {
-		if !reflect.DeepEqual(*rec, fixedTestStruct) {
-			t.Errorf("record[%d] %v != fixedTestStruct", i, *rec)
-		}
+	if want := []record{{1, "a"}, {2, "b"}, {3, "c"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records %v != %v", records, want)
 	}
 }
 
@@ -204,6 +3124,30 @@ func TestScanChan(t *testing.T) {
 	}
 }
 
+func TestScanChanCloseOnDone(t *testing.T) {
+	var (
+		sc      = sqlz.Scanner{CloseChanOnDone: true}
+		rows    = scantest.NewRows(4)
+		records = make(chan *testStruct)
+		errc    = make(chan error, 1)
+	)
+
+	go func() {
+		errc <- sc.Scan(context.Background(), rows, records)
+	}()
+
+	var recordCount int
+	for range records {
+		recordCount++
+	}
+	if err := <-errc; err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if recordCount != 4 {
+		t.Errorf("recordCount{%d} != 4", recordCount)
+	}
+}
+
 func TestScanChanCanceled(t *testing.T) {
 	var (
 		ctx, cancel = context.WithCancel(context.Background())
@@ -219,6 +3163,136 @@ func TestScanChanCanceled(t *testing.T) {
 	}
 }
 
+func TestColumnProfile(t *testing.T) {
+	var (
+		durations map[string]time.Duration
+		sc        = sqlz.Scanner{
+			ColumnProfile: func(d map[string]time.Duration) {
+				durations = d
+			},
+		}
+		rows = scantest.Query(t, []string{"id", "username"},
+			[]driver.Value{int64(1), "john_doe"},
+			[]driver.Value{int64(2), "jane_doe"},
+		)
+		records []testStructBase
+	)
+
+	err := sc.Scan(context.Background(), rows, &records)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if len(records) != 2 || records[0].ID != 1 || records[1].Username != "jane_doe" {
+		t.Errorf("records %v != [{1 john_doe} {2 jane_doe}]", records)
+	}
+	if _, ok := durations["username"]; len(durations) != 2 || !ok {
+		t.Errorf("durations %v don't have id and username", durations)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	var (
+		sc     sqlz.Scanner
+		record testStruct
+	)
+
+	for i := 0; i < 3; i++ {
+		if err := sc.Scan(context.Background(), scantest.NewRows(1), &record); err != nil {
+			t.Error("sc.Scan(...):", err)
+		}
+	}
+	stats := sc.CacheStats()
+	want := sqlz.CacheStats{Types: 1, Hits: 2, Misses: 1}
+	if stats != want {
+		t.Errorf("stats %+v != %+v", stats, want)
+	}
+
+	sc.PurgeCache()
+	if stats = sc.CacheStats(); stats.Types != 0 {
+		t.Errorf("stats.Types{%d} != 0", stats.Types)
+	}
+}
+
+type wrapper[T any] struct {
+	ID    int
+	Value T
+}
+
+func TestScanGenericStruct(t *testing.T) {
+	var (
+		sc      sqlz.Scanner
+		columns = []string{"id", "value"}
+		strs    []wrapper[string]
+		times   []wrapper[time.Time]
+		ts      = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+	)
+
+	err := sc.Scan(context.Background(), scantest.Query(t, columns, []driver.Value{int64(1), "a"}), &strs)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if want := []wrapper[string]{{1, "a"}}; !reflect.DeepEqual(strs, want) {
+		t.Errorf("strs %v != %v", strs, want)
+	}
+
+	err = sc.Scan(context.Background(), scantest.Query(t, columns, []driver.Value{int64(2), ts}), &times)
+
+	if err != nil {
+		t.Fatal("sc.Scan(...):", err)
+	}
+	if want := []wrapper[time.Time]{{2, ts}}; !reflect.DeepEqual(times, want) {
+		t.Errorf("times %v != %v", times, want)
+	}
+	if stats := sc.CacheStats(); stats.Types != 2 || stats.Misses != 2 {
+		t.Errorf("stats %+v != {Types:2 Misses:2}", stats)
+	}
+}
+
+func TestScanAnonymousStruct(t *testing.T) {
+	var sc sqlz.Scanner
+
+	for i := 0; i < 2; i++ {
+		record := struct {
+			ID   int
+			Name string
+		}{}
+		rows := scantest.Query(t, []string{"id", "name"}, []driver.Value{int64(i), "John"})
+
+		err := sc.Scan(context.Background(), rows, &record)
+
+		if err != nil {
+			t.Fatal("sc.Scan(...):", err)
+		}
+		if record.ID != i || record.Name != "John" {
+			t.Errorf("record %v != {%d John}", record, i)
+		}
+	}
+	if stats := sc.CacheStats(); stats.Types != 1 || stats.Hits != 1 {
+		t.Errorf("stats %+v != {Types:1 Hits:1}", stats)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	var (
+		sc     sqlz.Scanner
+		record testStruct
+	)
+
+	sc.Warm(testStruct{}, &[]*testStructBase{})
+
+	if stats := sc.CacheStats(); stats.Types != 2 || stats.Misses != 2 {
+		t.Errorf("stats %+v != {Types:2 Misses:2}", stats)
+	}
+	if err := sc.Scan(context.Background(), scantest.NewRows(1), &record); err != nil {
+		t.Error("sc.Scan(...):", err)
+	}
+	if stats := sc.CacheStats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("stats %+v != {Hits:1 Misses:2}", stats)
+	}
+}
+
 func BenchmarkScanStruct(b *testing.B) {
 	var (
 		sc sqlz.Scanner
@@ -237,6 +3311,23 @@ func BenchmarkScanStruct(b *testing.B) {
 	})
 }
 
+func BenchmarkScanStructHarness(b *testing.B) {
+	columns := []string{"id", "username", "display_name", "email", "age", "is_admin", "created_at"}
+	sqlztest.BenchmarkScan(b, nil, new(testStruct), columns)
+}
+
+func BenchmarkScanConvertersHarness(b *testing.B) {
+	type record struct {
+		IP    net.IP
+		Addr  netip.Addr
+		UUID  [16]byte
+		Meta  map[string]any    `db:"meta,json"`
+		Attrs map[string]string `db:"attrs,hstore"`
+		At    time.Time         `db:"at,epoch"`
+	}
+	sqlztest.BenchmarkScan(b, nil, new(record), []string{"ip", "addr", "uuid", "meta", "attrs", "at"})
+}
+
 func BenchmarkScanSlice(b *testing.B) {
 	var (
 		sc sqlz.Scanner
@@ -256,6 +3347,53 @@ func BenchmarkScanSlice(b *testing.B) {
 	})
 }
 
+func BenchmarkScanSliceInternStrings(b *testing.B) {
+	statuses := []string{"active", "pending", "suspended", "deleted"}
+	values := make([][]driver.Value, 1000)
+	for i := range values {
+		values[i] = []driver.Value{int64(i), []byte(statuses[i%len(statuses)])}
+	}
+	db := scantest.Open(b, []string{"id", "status"}, values...)
+
+	for _, intern := range []bool{false, true} {
+		b.Run(fmt.Sprintf("InternStrings=%t", intern), func(b *testing.B) {
+			var (
+				sc       = sqlz.Scanner{InternStrings: intern}
+				ms       runtime.MemStats
+				retained uint64
+			)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var records []struct {
+					ID     int
+					Status string
+				}
+				rows, err := db.Query("")
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err = sc.Scan(context.Background(), rows, &records); err != nil {
+					b.Error(err)
+				}
+				rows.Close()
+
+				b.StopTimer()
+				runtime.GC()
+				runtime.ReadMemStats(&ms)
+				before := ms.HeapAlloc
+				records = nil
+				runtime.GC()
+				runtime.ReadMemStats(&ms)
+				retained += before - ms.HeapAlloc
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
+
 func BenchmarkScanChan(b *testing.B) {
 	var (
 		sc sqlz.Scanner
@@ -281,3 +3419,135 @@ func BenchmarkScanChan(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkScanChanBatched(b *testing.B) {
+	ch := make(chan []*testStruct, 3)
+	go func() {
+		for range ch {
+			// drain
+		}
+	}()
+	b.Cleanup(func() {
+		close(ch)
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(p *testing.PB) {
+		for p.Next() {
+			rows := scantest.NewRows(30)
+			if err := sqlz.ScanChanBatched(context.Background(), nil, rows, ch, 10); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+// fuzzTags are the tags of the fields of the structs built by fuzzStruct.
+var fuzzTags = []reflect.StructTag{
+	``, `db:"a"`, `db:"b"`, `db:"-"`, `db:"a,json"`, `db:",extra"`, `db:",rest"`, `db:",rownum"`, `db:"#0"`,
+	`db:"a,setter=SetA"`, `db:"p"`, `db:"a,pgarray"`, `db:"a,ctx"`,
+}
+
+// fuzzTypes are the types of the non-struct fields of the structs built by fuzzStruct.
+var fuzzTypes = []reflect.Type{
+	reflect.TypeOf(0), reflect.TypeOf(""), reflect.TypeOf((*int)(nil)), reflect.TypeOf(time.Time{}),
+	reflect.TypeOf(map[string]any(nil)), reflect.TypeOf([]string(nil)), reflect.TypeOf(json.RawMessage(nil)),
+}
+
+// fuzzStruct builds a struct type from data, which is consumed from the front.
+func fuzzStruct(data *[]byte, depth int) reflect.Type {
+	next := func() int {
+		if len(*data) == 0 {
+			return 0
+		}
+		b := (*data)[0]
+		*data = (*data)[1:]
+		return int(b)
+	}
+	n := next()%6 + 1
+	fields := make([]reflect.StructField, n)
+	for i := range fields {
+		field := reflect.StructField{
+			Name: fmt.Sprintf("F%d", next()%8),
+			Tag:  fuzzTags[next()%len(fuzzTags)],
+		}
+		switch k := next() % 10; {
+		case k < 2 && depth < 12:
+			field.Type, field.Anonymous = fuzzStruct(data, depth+1), k == 0
+		case k == 2 && depth < 12:
+			field.Type = reflect.PointerTo(fuzzStruct(data, depth+1))
+		default:
+			field.Type = fuzzTypes[k%len(fuzzTypes)]
+		}
+		for _, f := range fields[:i] {
+			if f.Name == field.Name {
+				field.Name += fmt.Sprint("_", i) // reflect.StructOf rejects duplicate names
+			}
+		}
+		fields[i] = field
+	}
+	return reflect.StructOf(fields)
+}
+
+// fuzzColumns returns the candidate column names of the fields of t.
+func fuzzColumns(t reflect.Type, prefix string, columns []string) []string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+		switch ft := field.Type; {
+		case ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}):
+			columns = fuzzColumns(ft, prefix+name, columns)
+		case ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct:
+			columns = fuzzColumns(ft.Elem(), prefix+name, columns)
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		columns = append(columns, prefix+name)
+	}
+	return columns
+}
+
+func FuzzFieldIndex(f *testing.F) {
+	f.Add([]byte{3, 0, 1, 5, 1, 2, 0, 2, 1, 0, 1})
+	f.Add([]byte{2, 0, 0, 0, 1, 1, 1, 1, 2, 1, 1, 1})
+	f.Add([]byte{1, 0, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var (
+			typ     = fuzzStruct(&data, 0)
+			dest    = reflect.New(typ).Interface()
+			columns = fuzzColumns(typ, "", nil)
+			sc      = sqlz.Scanner{IgnoreUnknownColumns: true}
+		)
+
+		err := sc.Scannable(dest)
+
+		if err != nil {
+			if !errors.Is(err, sqlz.ErrMisuse) && !strings.Contains(err.Error(), "has no scannable fields") {
+				t.Fatalf("sc.Scannable(%s): unexpected err{%v}", typ, err)
+			}
+			return
+		}
+		mapping, err := sc.MappingFor(dest, columns)
+		if err != nil {
+			t.Fatalf("sc.MappingFor(%s, %q): %v", typ, columns, err)
+		}
+		for column, index := range mapping {
+			ft := typ
+			for _, i := range index {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() != reflect.Struct || i >= ft.NumField() {
+					t.Fatalf("column %q of %s maps to invalid index %v", column, typ, index)
+				}
+				ft = ft.Field(i).Type
+			}
+		}
+		again, err := sc.MappingFor(dest, columns)
+		if err != nil || !reflect.DeepEqual(again, mapping) {
+			t.Errorf("cached mapping %v != %v or err{%v} != nil", again, mapping, err)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+// Package sqlztest provides tools for testing code that uses sqlz.
+package sqlztest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/semrekkers/sqlz"
+	"github.com/semrekkers/sqlz/internal/scantest"
+	"github.com/semrekkers/sqlz/internal/tags"
+)
+
+// BenchmarkScan measures scanning a row with the given columns into dest, which must be a pointer to a struct.
+// The rows are served by an in-memory database driver, with a plausible value for each column based on its field:
+// its tag options, like epoch and json, or else its type, like 42 for integers, "value" for strings and an address
+// for net.IP. Fields of other types, like sql.Scanner implementations and kinds registered with the Scanner, receive
+// NULL. If s is nil, the global Scanner is used.
+//
+// It's called from the benchmarks of a package, like:
+//
+//	func BenchmarkScanUser(b *testing.B) {
+//		sqlztest.BenchmarkScan(b, nil, new(User), []string{"id", "name", "created_at"})
+//	}
+func BenchmarkScan(b *testing.B, s *sqlz.Scanner, dest any, columns []string) {
+	b.Helper()
+	if s == nil {
+		s = sqlz.Default()
+	}
+	mapping, err := s.MappingFor(dest, columns)
+	if err != nil {
+		b.Fatal("sqlztest:", err)
+	}
+	t := reflect.TypeOf(dest).Elem()
+	row := make([]driver.Value, len(columns))
+	for i, column := range columns {
+		if index, ok := mapping[column]; ok {
+			field := t.FieldByIndex(index)
+			_, opts := tags.Parse(tags.Lookup(field, s.Dialect, s.TagPriority))
+			row[i] = plausibleValue(field, opts)
+		}
+	}
+	rows, err := scantest.Repeat(b, columns, row).Query("")
+	if err != nil {
+		b.Fatal("sqlztest:", err)
+	}
+	defer rows.Close()
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := s.Scan(ctx, rows, dest); err != nil {
+			b.Fatal("sqlztest:", err)
+		}
+	}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	sampleTime   = time.Date(2023, 10, 10, 13, 14, 21, 0, time.UTC)
+	sampleValues = map[reflect.Type]driver.Value{
+		reflect.TypeOf(net.IP{}):     "192.0.2.1",
+		reflect.TypeOf(netip.Addr{}): "192.0.2.1",
+		reflect.TypeOf(url.URL{}):    "https://example.com/",
+		reflect.TypeOf(big.Int{}):    "42",
+		reflect.TypeOf(big.Float{}):  "4.2",
+		reflect.TypeOf(big.Rat{}):    "4.2",
+	}
+)
+
+// plausibleValue returns a driver value that can be scanned into field, whose tag has the options opts, or nil if
+// there's none.
+func plausibleValue(field reflect.StructField, opts tags.Options) driver.Value {
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case opts.Contains("epoch") || opts.Contains("epoch_ms"):
+		if t != timeType {
+			return sampleTime // int64 fields receive the timestamp of a time
+		} else if opts.Contains("epoch_ms") {
+			return sampleTime.UnixMilli()
+		}
+		return sampleTime.Unix()
+	case opts.Contains("json"):
+		b, err := json.Marshal(reflect.New(t).Interface())
+		if err != nil {
+			return nil
+		}
+		return b
+	case opts.Contains("hstore"):
+		return `"key"=>"value"`
+	case opts.Contains("pgarray"):
+		return "{}"
+	}
+	if t == timeType {
+		return sampleTime
+	}
+	if v, ok := sampleValues[t]; ok {
+		return v
+	}
+	if reflect.PointerTo(t).Implements(scannerType) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(42)
+	case reflect.Float32, reflect.Float64:
+		return float64(4.2)
+	case reflect.String:
+		return "value"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return []byte("value")
+		}
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return make([]byte, t.Len()) // byte arrays, like UUIDs, receive a slice of their length
+		}
+	}
+	return nil
+}